@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Auto-naming
+// -----------------------------------------------------------------------------
+
+// AutoName is the auto_name config section. With Daemon set, `gnav daemon`
+// keeps unnamed workspaces named after their dominant app as windows come
+// and go, like sway's autoname scripts. Workspaces named by hand are left
+// alone unless IncludeNamed is set.
+type AutoName struct {
+	Daemon       bool `yaml:"daemon,omitempty"`
+	IncludeNamed bool `yaml:"include_named,omitempty"`
+}
+
+// autoNameInterval is how often the daemon looks at the windows when
+// auto-naming is on; window changes don't arrive as workspace events.
+const autoNameInterval = 2 * time.Second
+
+// autoNameable reports whether auto-naming may name the 0-based workspace
+// i: it has no name of its own, was auto-named before, or include_named
+// is set.
+func autoNameable(i int, meta WorkspaceMeta) bool {
+	name := strings.TrimSpace(meta.Name)
+	return meta.Auto || name == "" || name == defaultName(i) || cfg.AutoName.IncludeNamed
+}
+
+// dominantClass returns the WM_CLASS with the most windows on the 0-based
+// workspace desktop. Ties go to the focused window's class, then to the
+// class listed first. It is empty for a workspace without windows.
+func dominantClass(wins []windowInfo, desktop int, activeID uint64) string {
+	counts := map[string]int{}
+	var order []string
+	focused := ""
+	for _, w := range wins {
+		if w.Desktop != desktop {
+			continue
+		}
+		if counts[w.Class] == 0 {
+			order = append(order, w.Class)
+		}
+		counts[w.Class]++
+		if id, err := strconv.ParseUint(w.ID, 0, 64); err == nil && id == activeID {
+			focused = w.Class
+		}
+	}
+	best := ""
+	for _, c := range order {
+		if best == "" || counts[c] > counts[best] || counts[c] == counts[best] && c == focused {
+			best = c
+		}
+	}
+	return best
+}
+
+// autoNameAll names every auto-nameable workspace after its dominant app,
+// mapped through class_name_map, and gives auto-named workspaces that have
+// emptied their default name back. It returns one line per change.
+func autoNameAll() ([]string, error) {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return nil, err
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return nil, err
+	}
+	dyn, _ := getDynamic()
+	activeID, _ := getActiveWindowID()
+
+	// plan maps workspaces to their new name; "" restores the default.
+	plan := map[int]string{}
+	for i := 0; i < sc; i++ {
+		meta := workspaceMeta(i)
+		if dyn && i == sc-1 || !autoNameable(i, meta) {
+			continue
+		}
+		class := dominantClass(wins, i, activeID)
+		switch {
+		case class != "":
+			if name := friendlyClassName(class); name != meta.Name {
+				plan[i] = name
+			}
+		case meta.Auto:
+			plan[i] = ""
+		}
+	}
+	if len(plan) == 0 {
+		return nil, nil
+	}
+
+	var changes []string
+	err = updateConfig(func() error {
+		changes = nil
+		for i := 0; i < sc; i++ {
+			name, ok := plan[i]
+			if !ok {
+				continue
+			}
+			ensureWorkspaces(i + 1)
+			w := &cfg.Workspaces[i]
+			old := w.Name
+			w.Name, w.Auto = name, true
+			if name == "" {
+				w.Name, w.Auto = defaultName(i), false
+			}
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", formatIndex(i), old, w.Name))
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// autoNameLoop runs autoNameAll every autoNameInterval while auto_name.daemon
+// is set, until stop is closed. The setting is read on every tick, so it
+// follows config edits.
+func autoNameLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(autoNameInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		cfgMu.Lock()
+		reloadConfig()
+		if cfg.AutoName.Daemon {
+			_, _ = autoNameAll()
+		}
+		cfgMu.Unlock()
+	}
+}
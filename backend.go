@@ -0,0 +1,437 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// -----------------------------------------------------------------------------
+// Workspace backends
+// -----------------------------------------------------------------------------
+
+// WorkspaceBackend reads and changes the desktop's workspaces. Indices are
+// 0-based, as the window managers themselves count them. Backends without
+// a notion of dynamic workspaces report false and reject enabling it.
+type WorkspaceBackend interface {
+	Count() (int, error)
+	ActiveIndex() (int, error)
+	Switch(idx int) error
+	SetCount(n int) error
+	Dynamic() (bool, error)
+	SetDynamic(on bool) error
+}
+
+// windowLister is implemented by backends that can list windows natively;
+// listWindows falls back to wmctrl for the others.
+type windowLister interface {
+	Windows() ([]windowInfo, error)
+}
+
+// windowMover is implemented by backends that can move the focused window
+// themselves; moveActiveWindow falls back to wmctrl for the others. idx is
+// 0-based.
+type windowMover interface {
+	MoveActiveWindow(idx int) error
+}
+
+// windowController is implemented by backends that can act on windows by
+// the ID they report in windowInfo; focusWindow and moveWindow fall back to
+// wmctrl for the others. idx is 0-based.
+type windowController interface {
+	FocusWindow(id string) error
+	MoveWindow(id string, idx int) error
+}
+
+// outputLister is implemented by backends that know which output each
+// workspace is on, keyed by 0-based index; next and prev need it for
+// --screen-relative.
+type outputLister interface {
+	WorkspaceOutputs() (map[int]string, error)
+}
+
+// errCountUnsupported is SetCount's error on backends whose window manager
+// creates workspaces as they are used and drops them once empty.
+var errCountUnsupported = errors.New("setting the workspace count is not supported by this backend; it creates workspaces on demand")
+
+// onDemandWorkspaces reports whether the backend is one of those, so
+// commands that change the count can refuse before touching any window.
+func onDemandWorkspaces() bool {
+	switch backend.(type) {
+	case i3Backend, hyprlandBackend:
+		return true
+	}
+	return false
+}
+
+// backend is picked once at startup by main.
+var backend WorkspaceBackend = wmctrlBackend{}
+
+// backends maps the names accepted by the backend config key.
+var backends = map[string]func(getenv func(string) string) WorkspaceBackend{
+	"wmctrl":      func(func(string) string) WorkspaceBackend { return wmctrlBackend{} },
+	"gnome-shell": func(func(string) string) WorkspaceBackend { return gnomeShellBackend{} },
+	"i3":          func(getenv func(string) string) WorkspaceBackend { return i3Backend{i3Socket(getenv)} },
+	"sway":        func(getenv func(string) string) WorkspaceBackend { return i3Backend{i3Socket(getenv)} },
+	"hyprland":    func(func(string) string) WorkspaceBackend { return hyprlandBackend{} },
+	"kde":         func(func(string) string) WorkspaceBackend { return kdeBackend{} },
+	"x11": func(getenv func(string) string) WorkspaceBackend {
+		b, err := newX11Backend(getenv("DISPLAY"))
+		if err != nil {
+			return unavailableBackend{fmt.Errorf("x11: %w", err)}
+		}
+		return b
+	},
+}
+
+// backendByName returns the named backend, or detects one for "auto" and
+// the empty string. A forced backend is used even when what it talks to
+// is missing, with a warning on stderr so the later failure makes sense.
+func backendByName(name string, getenv func(string) string, lookPath func(string) (string, error)) (WorkspaceBackend, error) {
+	if name == "" || name == "auto" {
+		return selectBackend(getenv, lookPath)
+	}
+	newBackend, ok := backends[name]
+	if !ok {
+		var names []string
+		for n := range backends {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown backend %q (supported: auto, %s)", name, strings.Join(names, ", "))
+	}
+	if missing := backendMissing(name, getenv, lookPath); missing != "" {
+		fmt.Fprintf(os.Stderr, "gnav: warning: backend %s: %s\n", name, missing)
+	}
+	return newBackend(getenv), nil
+}
+
+// backendMissing describes what the named backend needs but cannot find,
+// or returns "" when nothing is missing.
+func backendMissing(name string, getenv func(string) string, lookPath func(string) (string, error)) string {
+	var bin string
+	switch name {
+	case "i3", "sway":
+		sock := i3Socket(getenv)
+		if sock == "" {
+			return "no IPC socket ($I3SOCK or $SWAYSOCK is unset)"
+		}
+		if _, err := os.Stat(sock); err != nil {
+			return "IPC socket " + sock + " not found"
+		}
+		return ""
+	case "hyprland":
+		bin = "hyprctl"
+	case "kde":
+		bin = "gdbus"
+	case "wmctrl":
+		bin = "wmctrl"
+	default:
+		return ""
+	}
+	if _, err := lookPath(bin); err != nil {
+		return bin + " not found"
+	}
+	return ""
+}
+
+// selectBackend picks the backend for the session described by getenv.
+// i3, sway and Hyprland advertise themselves in the environment, and KDE
+// sessions are driven through KWin on both X11 and Wayland. wmctrl has no
+// Wayland support, so GNOME Wayland sessions go through gnav's GNOME Shell
+// extension over D-Bus instead. Other X11 sessions talk EWMH directly over the X
+// connection, with wmctrl kept as a fallback when that connection fails.
+func selectBackend(getenv func(string) string, lookPath func(string) (string, error)) (WorkspaceBackend, error) {
+	if sock := i3Socket(getenv); sock != "" {
+		return i3Backend{sock}, nil
+	}
+	if getenv("HYPRLAND_INSTANCE_SIGNATURE") != "" {
+		if _, err := lookPath("hyprctl"); err != nil {
+			return nil, errors.New("hyprland session: hyprctl not found")
+		}
+		return hyprlandBackend{}, nil
+	}
+	if desktopIs(getenv, "KDE") {
+		if _, err := lookPath("gdbus"); err != nil {
+			return nil, errors.New("kde session: gdbus is needed to control KWin desktops")
+		}
+		return kdeBackend{}, nil
+	}
+	if sessionType(getenv) == "wayland" {
+		if !isGNOME(getenv) {
+			return nil, fmt.Errorf("unsupported Wayland desktop %q: only GNOME is supported", getenv("XDG_CURRENT_DESKTOP"))
+		}
+		return gnomeShellBackend{}, nil
+	}
+	if display := getenv("DISPLAY"); display != "" {
+		if b, err := newX11Backend(display); err == nil {
+			return b, nil
+		}
+	}
+	if _, err := lookPath("wmctrl"); err != nil {
+		return nil, errors.New("no usable workspace backend: install wmctrl (X11) or run GNOME on Wayland with gnav's Shell extension")
+	}
+	return wmctrlBackend{}, nil
+}
+
+// sessionType returns "wayland" or "x11". XDG_SESSION_TYPE is missing when
+// gnav is started outside a login session (e.g. from some terminals over
+// ssh -X or systemd units), so WAYLAND_DISPLAY is used as a fallback.
+func sessionType(getenv func(string) string) string {
+	switch t := strings.ToLower(getenv("XDG_SESSION_TYPE")); t {
+	case "wayland", "x11":
+		return t
+	}
+	if getenv("WAYLAND_DISPLAY") != "" {
+		return "wayland"
+	}
+	return "x11"
+}
+
+// isGNOME reports whether the session is GNOME. When XDG_CURRENT_DESKTOP
+// is unset GNOME is assumed.
+func isGNOME(getenv func(string) string) bool {
+	return getenv("XDG_CURRENT_DESKTOP") == "" || desktopIs(getenv, "GNOME")
+}
+
+// desktopIs reports whether XDG_CURRENT_DESKTOP, a colon-separated list such
+// as "ubuntu:GNOME", names the given desktop.
+func desktopIs(getenv func(string) string, name string) bool {
+	for _, d := range strings.Split(getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// unavailableBackend reports why no backend could be selected, so commands
+// that don't touch workspaces keep working.
+type unavailableBackend struct{ err error }
+
+func (b unavailableBackend) Count() (int, error)       { return 0, b.err }
+func (b unavailableBackend) ActiveIndex() (int, error) { return -1, b.err }
+func (b unavailableBackend) Switch(int) error          { return b.err }
+func (b unavailableBackend) SetCount(int) error        { return b.err }
+func (b unavailableBackend) Dynamic() (bool, error)    { return false, b.err }
+func (b unavailableBackend) SetDynamic(bool) error     { return b.err }
+
+// -----------------------------------------------------------------------------
+// GNOME settings
+// -----------------------------------------------------------------------------
+
+// gnomeSettings implements the workspace count and dynamic mode through
+// GNOME's gsettings keys; the GNOME backends embed it.
+type gnomeSettings struct{}
+
+func (gnomeSettings) SetCount(n int) error {
+	return exec.Command("gsettings", "set",
+		"org.gnome.desktop.wm.preferences", "num-workspaces", strconv.Itoa(n)).Run()
+}
+
+func (gnomeSettings) Dynamic() (bool, error) {
+	out, err := exec.Command("gsettings", "get",
+		"org.gnome.mutter", "dynamic-workspaces").Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+func (gnomeSettings) SetDynamic(on bool) error {
+	val := "false"
+	if on {
+		val = "true"
+	}
+	return exec.Command("gsettings", "set",
+		"org.gnome.mutter", "dynamic-workspaces", val).Run()
+}
+
+// -----------------------------------------------------------------------------
+// wmctrl (X11)
+// -----------------------------------------------------------------------------
+
+type wmctrlBackend struct{ gnomeSettings }
+
+func (wmctrlBackend) Count() (int, error) {
+	out, err := exec.Command("wmctrl", "-d").Output()
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return len(lines), nil
+}
+
+func (wmctrlBackend) ActiveIndex() (int, error) {
+	out, err := exec.Command("wmctrl", "-d").Output()
+	if err != nil {
+		return -1, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "*") {
+			return i, nil
+		}
+	}
+	return -1, errors.New("no active workspace found")
+}
+
+func (wmctrlBackend) Switch(idx int) error {
+	return exec.Command("wmctrl", "-s", strconv.Itoa(idx)).Run()
+}
+
+func (wmctrlBackend) MoveActiveWindow(idx int) error {
+	out, err := exec.Command("wmctrl", "-r", ":ACTIVE:", "-t", strconv.Itoa(idx)).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("wmctrl: %v: %s", err, msg)
+		}
+		return fmt.Errorf("wmctrl: %v", err)
+	}
+	return nil
+}
+
+func (wmctrlBackend) FocusWindow(id string) error {
+	return exec.Command("wmctrl", "-i", "-a", id).Run()
+}
+
+func (wmctrlBackend) MoveWindow(id string, idx int) error {
+	return exec.Command("wmctrl", "-i", "-r", id, "-t", strconv.Itoa(idx)).Run()
+}
+
+// Windows parses `wmctrl -lx`.
+func (wmctrlBackend) Windows() ([]windowInfo, error) {
+	out, err := exec.Command("wmctrl", "-lx").Output()
+	if err != nil {
+		return nil, err
+	}
+	var wins []windowInfo
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 4 {
+			continue
+		}
+		d, err := strconv.Atoi(f[1])
+		if err != nil {
+			continue
+		}
+		wins = append(wins, windowInfo{
+			ID:      f[0],
+			Desktop: d,
+			Class:   f[2],
+			Title:   strings.Join(f[4:], " "),
+		})
+	}
+	return wins, nil
+}
+
+// -----------------------------------------------------------------------------
+// GNOME Shell over D-Bus (Wayland)
+// -----------------------------------------------------------------------------
+
+// On Wayland, GNOME Shell is the only one that knows the workspaces and
+// windows, and it stopped running code for other programs through
+// org.gnome.Shell.Eval in GNOME 41. gnav's companion extension (see
+// shell-extension/ and `gnav shell-extension install`) exports what it
+// needs instead.
+const (
+	shellExtPath  = "/org/gnome/Shell/Extensions/Gnav"
+	shellExtIface = "org.gnome.Shell.Extensions.Gnav"
+)
+
+type gnomeShellBackend struct{ gnomeSettings }
+
+// shellCall calls method on the companion extension and stores its reply
+// in out.
+func shellCall(method string, out []interface{}, args ...interface{}) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return fmt.Errorf("gnome-shell: %v", err)
+	}
+	call := conn.Object("org.gnome.Shell", shellExtPath).Call(shellExtIface+"."+method, 0, args...)
+	if call.Err != nil {
+		name := ""
+		var de dbus.Error
+		var dep *dbus.Error
+		if errors.As(call.Err, &de) {
+			name = de.Name
+		} else if errors.As(call.Err, &dep) {
+			name = dep.Name
+		}
+		switch name {
+		case "org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.UnknownObject",
+			"org.freedesktop.DBus.Error.UnknownInterface":
+			return errors.New("gnome-shell: the gnav GNOME Shell extension isn't running; " +
+				"run 'gnav shell-extension install', then log out and back in")
+		}
+		return fmt.Errorf("gnome-shell: %v", call.Err)
+	}
+	return call.Store(out...)
+}
+
+func shellCallInt(method string) (int, error) {
+	var n int32
+	if err := shellCall(method, []interface{}{&n}); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func (gnomeShellBackend) Count() (int, error) {
+	return shellCallInt("Count")
+}
+
+func (gnomeShellBackend) ActiveIndex() (int, error) {
+	return shellCallInt("ActiveIndex")
+}
+
+func (gnomeShellBackend) Switch(idx int) error {
+	return shellCall("Switch", nil, int32(idx))
+}
+
+func (gnomeShellBackend) Windows() ([]windowInfo, error) {
+	var js string
+	if err := shellCall("Windows", []interface{}{&js}); err != nil {
+		return nil, err
+	}
+	return decodeShellWindows(js)
+}
+
+func (gnomeShellBackend) FocusWindow(id string) error {
+	return shellCall("FocusWindow", nil, id)
+}
+
+func (gnomeShellBackend) MoveWindow(id string, idx int) error {
+	return shellCall("MoveWindow", nil, id, int32(idx))
+}
+
+func (gnomeShellBackend) MoveActiveWindow(idx int) error {
+	return shellCall("MoveActiveWindow", nil, int32(idx))
+}
+
+// decodeShellWindows parses the extension's Windows reply, a JSON array of
+// {id, desktop, class, title} objects.
+func decodeShellWindows(js string) ([]windowInfo, error) {
+	var raw []struct {
+		ID      string `json:"id"`
+		Desktop int    `json:"desktop"`
+		Class   string `json:"class"`
+		Title   string `json:"title"`
+	}
+	if err := json.Unmarshal([]byte(js), &raw); err != nil {
+		return nil, fmt.Errorf("gnome-shell: unexpected window list: %v", err)
+	}
+	wins := make([]windowInfo, len(raw))
+	for i, w := range raw {
+		wins[i] = windowInfo{ID: w.ID, Desktop: w.Desktop, Class: w.Class, Title: w.Title}
+	}
+	return wins, nil
+}
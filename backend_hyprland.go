@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Hyprland
+// -----------------------------------------------------------------------------
+
+// hyprlandBackend drives Hyprland through hyprctl. Like i3, workspaces are
+// addressed by id, so gnav index i is workspace id i+1; special (negative
+// id) workspaces are ignored.
+type hyprlandBackend struct{}
+
+type hyprWorkspace struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Windows int    `json:"windows"`
+	Monitor string `json:"monitor"`
+}
+
+func hyprctlJSON(what string, v interface{}) error {
+	out, err := exec.Command("hyprctl", "-j", what).Output()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out, v); err != nil {
+		return fmt.Errorf("hyprctl %s: %v", what, err)
+	}
+	return nil
+}
+
+func (hyprlandBackend) Count() (int, error) {
+	var ws []hyprWorkspace
+	if err := hyprctlJSON("workspaces", &ws); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, w := range ws {
+		n = max(n, w.ID)
+	}
+	return n, nil
+}
+
+func (hyprlandBackend) ActiveIndex() (int, error) {
+	var w hyprWorkspace
+	if err := hyprctlJSON("activeworkspace", &w); err != nil {
+		return -1, err
+	}
+	if w.ID < 1 {
+		return -1, fmt.Errorf("active workspace %q has no positive id", w.Name)
+	}
+	return w.ID - 1, nil
+}
+
+func (hyprlandBackend) WorkspaceOutputs() (map[int]string, error) {
+	var ws []hyprWorkspace
+	if err := hyprctlJSON("workspaces", &ws); err != nil {
+		return nil, err
+	}
+	outputs := make(map[int]string)
+	for _, w := range ws {
+		if w.ID >= 1 {
+			outputs[w.ID-1] = w.Monitor
+		}
+	}
+	return outputs, nil
+}
+
+// dispatch runs a hyprctl dispatcher, which answers "ok" on success.
+func dispatch(args ...string) error {
+	out, err := exec.Command("hyprctl", append([]string{"dispatch"}, args...)...).Output()
+	if err != nil {
+		return err
+	}
+	if reply := strings.TrimSpace(string(out)); reply != "ok" {
+		return fmt.Errorf("hyprctl dispatch: %s", reply)
+	}
+	return nil
+}
+
+func (hyprlandBackend) Switch(idx int) error {
+	return dispatch("workspace", strconv.Itoa(idx+1))
+}
+
+func (hyprlandBackend) FocusWindow(id string) error {
+	return dispatch("focuswindow", "address:"+id)
+}
+
+func (hyprlandBackend) MoveWindow(id string, idx int) error {
+	return dispatch("movetoworkspacesilent", fmt.Sprintf("%d,address:%s", idx+1, id))
+}
+
+func (hyprlandBackend) MoveActiveWindow(idx int) error {
+	return dispatch("movetoworkspacesilent", strconv.Itoa(idx+1))
+}
+
+// SetCount is unsupported: Hyprland creates workspaces on demand.
+func (hyprlandBackend) SetCount(int) error { return errCountUnsupported }
+
+func (hyprlandBackend) Dynamic() (bool, error) { return false, nil }
+
+func (hyprlandBackend) SetDynamic(on bool) error {
+	if on {
+		return errors.New("dynamic workspaces are not supported by Hyprland")
+	}
+	return nil
+}
+
+type hyprClient struct {
+	Address   string        `json:"address"`
+	Workspace hyprWorkspace `json:"workspace"`
+	Class     string        `json:"class"`
+	Title     string        `json:"title"`
+	Pinned    bool          `json:"pinned"`
+}
+
+// Windows implements windowLister through `hyprctl clients`. Windows on
+// special workspaces are left out; pinned ones are sticky.
+func (hyprlandBackend) Windows() ([]windowInfo, error) {
+	var clients []hyprClient
+	if err := hyprctlJSON("clients", &clients); err != nil {
+		return nil, err
+	}
+	var wins []windowInfo
+	for _, c := range clients {
+		if c.Workspace.ID < 1 {
+			continue
+		}
+		d := c.Workspace.ID - 1
+		if c.Pinned {
+			d = -1
+		}
+		wins = append(wins, windowInfo{ID: c.Address, Desktop: d, Class: c.Class, Title: c.Title})
+	}
+	return wins, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// i3 / sway IPC
+// -----------------------------------------------------------------------------
+
+// i3Backend talks to i3 or sway over their shared IPC protocol. Workspaces
+// are addressed by number, so gnav index i is workspace number i+1 and the
+// count is the highest numbered workspace that currently exists.
+type i3Backend struct {
+	socket string
+}
+
+const (
+	i3Magic         = "i3-ipc"
+	i3RunCommand    = 0
+	i3GetWorkspaces = 1
+	i3GetTree       = 4
+)
+
+type i3Workspace struct {
+	Num     int    `json:"num"`
+	Name    string `json:"name"`
+	Focused bool   `json:"focused"`
+	Output  string `json:"output"`
+}
+
+// request sends one IPC message and returns the reply payload. The protocol
+// uses native byte order, which is little-endian on every platform sway and
+// i3 ship on.
+func (b i3Backend) request(typ uint32, payload string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", b.socket, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msg := make([]byte, len(i3Magic)+8, len(i3Magic)+8+len(payload))
+	copy(msg, i3Magic)
+	binary.LittleEndian.PutUint32(msg[6:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(msg[10:], typ)
+	msg = append(msg, payload...)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	hdr := make([]byte, len(i3Magic)+8)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr[:6]) != i3Magic {
+		return nil, errors.New("i3 ipc: bad reply magic")
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(hdr[6:]))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (b i3Backend) workspaces() ([]i3Workspace, error) {
+	body, err := b.request(i3GetWorkspaces, "")
+	if err != nil {
+		return nil, err
+	}
+	var ws []i3Workspace
+	if err := json.Unmarshal(body, &ws); err != nil {
+		return nil, fmt.Errorf("i3 ipc: %v", err)
+	}
+	return ws, nil
+}
+
+// command runs an i3 command and reports the first failure it returns.
+func (b i3Backend) command(cmd string) error {
+	body, err := b.request(i3RunCommand, cmd)
+	if err != nil {
+		return err
+	}
+	var results []struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &results); err != nil {
+		return fmt.Errorf("i3 ipc: %v", err)
+	}
+	for _, r := range results {
+		if !r.Success {
+			return fmt.Errorf("i3 ipc: %s", r.Error)
+		}
+	}
+	return nil
+}
+
+func (b i3Backend) Count() (int, error) {
+	ws, err := b.workspaces()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, w := range ws {
+		n = max(n, w.Num)
+	}
+	return n, nil
+}
+
+func (b i3Backend) ActiveIndex() (int, error) {
+	ws, err := b.workspaces()
+	if err != nil {
+		return -1, err
+	}
+	for _, w := range ws {
+		if w.Focused {
+			if w.Num < 1 {
+				return -1, fmt.Errorf("focused workspace %q has no number", w.Name)
+			}
+			return w.Num - 1, nil
+		}
+	}
+	return -1, errors.New("no active workspace found")
+}
+
+func (b i3Backend) WorkspaceOutputs() (map[int]string, error) {
+	ws, err := b.workspaces()
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[int]string)
+	for _, w := range ws {
+		if w.Num >= 1 {
+			outputs[w.Num-1] = w.Output
+		}
+	}
+	return outputs, nil
+}
+
+func (b i3Backend) Switch(idx int) error {
+	return b.command(fmt.Sprintf("workspace number %d", idx+1))
+}
+
+func (b i3Backend) MoveActiveWindow(idx int) error {
+	return b.command(fmt.Sprintf("move container to workspace number %d", idx+1))
+}
+
+// i3Node is a container in the GET_TREE reply. Windows are the leaves:
+// X11 ones carry window and window_properties, sway's native Wayland ones
+// an app_id instead.
+type i3Node struct {
+	ID               int64    `json:"id"`
+	Type             string   `json:"type"`
+	Name             string   `json:"name"`
+	Num              int      `json:"num"`
+	Window           *int64   `json:"window"`
+	AppID            *string  `json:"app_id"`
+	Sticky           bool     `json:"sticky"`
+	Nodes            []i3Node `json:"nodes"`
+	FloatingNodes    []i3Node `json:"floating_nodes"`
+	WindowProperties struct {
+		Class    string `json:"class"`
+		Instance string `json:"instance"`
+	} `json:"window_properties"`
+}
+
+func (b i3Backend) Windows() ([]windowInfo, error) {
+	body, err := b.request(i3GetTree, "")
+	if err != nil {
+		return nil, err
+	}
+	return i3TreeWindows(body)
+}
+
+// i3TreeWindows lists the windows in a GET_TREE reply, with their con_id
+// as ID. Windows on unnumbered workspaces, such as the scratchpad, are
+// left out, and sticky ones report desktop -1.
+func i3TreeWindows(body []byte) ([]windowInfo, error) {
+	var root i3Node
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("i3 ipc: %v", err)
+	}
+	var wins []windowInfo
+	var walk func(n i3Node, desktop int)
+	walk = func(n i3Node, desktop int) {
+		if n.Type == "workspace" {
+			desktop = n.Num - 1
+		}
+		if desktop >= 0 && len(n.Nodes) == 0 && len(n.FloatingNodes) == 0 && (n.Window != nil || n.AppID != nil) {
+			class := n.WindowProperties.Instance + "." + n.WindowProperties.Class
+			if n.AppID != nil {
+				class = *n.AppID
+			}
+			d := desktop
+			if n.Sticky {
+				d = -1
+			}
+			wins = append(wins, windowInfo{ID: strconv.FormatInt(n.ID, 10), Desktop: d, Class: class, Title: n.Name})
+		}
+		for _, c := range n.Nodes {
+			walk(c, desktop)
+		}
+		for _, c := range n.FloatingNodes {
+			walk(c, desktop)
+		}
+	}
+	walk(root, -1)
+	return wins, nil
+}
+
+// conCommand runs cmd on the container with the given con_id.
+func (b i3Backend) conCommand(id, cmd string) error {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return fmt.Errorf("invalid i3 container id %q", id)
+	}
+	return b.command(fmt.Sprintf("[con_id=%s] %s", id, cmd))
+}
+
+func (b i3Backend) FocusWindow(id string) error {
+	return b.conCommand(id, "focus")
+}
+
+func (b i3Backend) MoveWindow(id string, idx int) error {
+	return b.conCommand(id, fmt.Sprintf("move container to workspace number %d", idx+1))
+}
+
+// SetCount is unsupported: i3 and sway create numbered workspaces on demand.
+func (b i3Backend) SetCount(int) error { return errCountUnsupported }
+
+// Dynamic is always false; i3 has no GNOME-style trailing empty workspace.
+func (b i3Backend) Dynamic() (bool, error) { return false, nil }
+
+func (b i3Backend) SetDynamic(on bool) error {
+	if on {
+		return errors.New("dynamic workspaces are not supported by i3/sway")
+	}
+	return nil
+}
+
+// i3Socket returns the IPC socket advertised in the environment, preferring
+// sway's.
+func i3Socket(getenv func(string) string) string {
+	for _, k := range []string{"SWAYSOCK", "I3SOCK"} {
+		if s := strings.TrimSpace(getenv(k)); s != "" {
+			return s
+		}
+	}
+	return ""
+}
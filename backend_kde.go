@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// -----------------------------------------------------------------------------
+// KDE Plasma (KWin over D-Bus)
+// -----------------------------------------------------------------------------
+
+// kdeBackend drives KWin's virtual desktops through the VirtualDesktopManager
+// D-Bus object, which Plasma 5.2x and 6 both provide. Desktops are
+// identified by id there; gnav indices map to their position.
+type kdeBackend struct{}
+
+const (
+	kwinDest  = "org.kde.KWin"
+	kwinPath  = "/VirtualDesktopManager"
+	kwinIface = "org.kde.KWin.VirtualDesktopManager"
+)
+
+type kwinDesktop struct {
+	Position int
+	ID       string
+}
+
+var (
+	gvUint32Re  = regexp.MustCompile(`uint32 (\d+)`)
+	gvStringRe  = regexp.MustCompile(`'([^']*)'`)
+	gvDesktopRe = regexp.MustCompile(`\(uint32 (\d+), '([^']*)', `)
+)
+
+func kwinCall(method string, args ...string) (string, error) {
+	argv := append([]string{"call", "--session", "--dest", kwinDest,
+		"--object-path", kwinPath, "--method", method}, args...)
+	out, err := exec.Command("gdbus", argv...).Output()
+	if err != nil {
+		return "", fmt.Errorf("kwin %s: %v", method, err)
+	}
+	return string(out), nil
+}
+
+func kwinProperty(name string) (string, error) {
+	return kwinCall("org.freedesktop.DBus.Properties.Get", kwinIface, name)
+}
+
+// kwinDesktops parses the desktops property, rendered by gdbus as
+// "(<[(uint32 0, 'id', 'Desktop 1'), ...]>,)", ordered by position.
+func kwinDesktops() ([]kwinDesktop, error) {
+	out, err := kwinProperty("desktops")
+	if err != nil {
+		return nil, err
+	}
+	return parseKWinDesktops(out)
+}
+
+func parseKWinDesktops(out string) ([]kwinDesktop, error) {
+	var ds []kwinDesktop
+	for _, m := range gvDesktopRe.FindAllStringSubmatch(out, -1) {
+		pos, _ := strconv.Atoi(m[1])
+		ds = append(ds, kwinDesktop{Position: pos, ID: m[2]})
+	}
+	if len(ds) == 0 {
+		return nil, fmt.Errorf("unexpected KWin desktops reply: %q", out)
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Position < ds[j].Position })
+	return ds, nil
+}
+
+func (kdeBackend) Count() (int, error) {
+	out, err := kwinProperty("count")
+	if err != nil {
+		return 0, err
+	}
+	m := gvUint32Re.FindStringSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("unexpected KWin count reply: %q", out)
+	}
+	return strconv.Atoi(m[1])
+}
+
+func (kdeBackend) ActiveIndex() (int, error) {
+	out, err := kwinProperty("current")
+	if err != nil {
+		return -1, err
+	}
+	m := gvStringRe.FindStringSubmatch(out)
+	if m == nil {
+		return -1, fmt.Errorf("unexpected KWin current reply: %q", out)
+	}
+	ds, err := kwinDesktops()
+	if err != nil {
+		return -1, err
+	}
+	for i, d := range ds {
+		if d.ID == m[1] {
+			return i, nil
+		}
+	}
+	return -1, errors.New("no active workspace found")
+}
+
+func (kdeBackend) Switch(idx int) error {
+	ds, err := kwinDesktops()
+	if err != nil {
+		return err
+	}
+	if idx < 0 || idx >= len(ds) {
+		return fmt.Errorf("no desktop %d", idx+1)
+	}
+	_, err = kwinCall("org.freedesktop.DBus.Properties.Set", kwinIface, "current",
+		fmt.Sprintf("<'%s'>", ds[idx].ID))
+	return err
+}
+
+// SetCount appends or removes desktops at the end until there are n.
+func (b kdeBackend) SetCount(n int) error {
+	ds, err := kwinDesktops()
+	if err != nil {
+		return err
+	}
+	for i := len(ds); i < n; i++ {
+		if _, err := kwinCall(kwinIface+".createDesktop", strconv.Itoa(i), defaultName(i)); err != nil {
+			return err
+		}
+	}
+	for i := len(ds) - 1; i >= n && i > 0; i-- {
+		if _, err := kwinCall(kwinIface+".removeDesktop", ds[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dynamic is always false; KWin keeps a fixed set of desktops.
+func (kdeBackend) Dynamic() (bool, error) { return false, nil }
+
+func (kdeBackend) SetDynamic(on bool) error {
+	if on {
+		return errors.New("dynamic workspaces are not supported by KWin")
+	}
+	return nil
+}
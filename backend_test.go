@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeEnv returns a getenv over env and a lookPath that only finds bins.
+func fakeEnv(env map[string]string, bins ...string) (func(string) string, func(string) (string, error)) {
+	getenv := func(k string) string { return env[k] }
+	lookPath := func(name string) (string, error) {
+		for _, b := range bins {
+			if b == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	return getenv, lookPath
+}
+
+func TestSelectBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		bins []string
+		want string // backend type, or "" for an error
+	}{
+		{"sway", map[string]string{"SWAYSOCK": "/run/sway.sock", "XDG_SESSION_TYPE": "wayland"}, nil, "main.i3Backend"},
+		{"i3", map[string]string{"I3SOCK": "/run/i3.sock", "DISPLAY": ":0"}, nil, "main.i3Backend"},
+		{"hyprland", map[string]string{"HYPRLAND_INSTANCE_SIGNATURE": "abc"}, []string{"hyprctl"}, "main.hyprlandBackend"},
+		{"hyprland without hyprctl", map[string]string{"HYPRLAND_INSTANCE_SIGNATURE": "abc"}, nil, ""},
+		{"kde wayland", map[string]string{"XDG_CURRENT_DESKTOP": "KDE", "XDG_SESSION_TYPE": "wayland"}, []string{"gdbus"}, "main.kdeBackend"},
+		{"kde without gdbus", map[string]string{"XDG_CURRENT_DESKTOP": "KDE"}, nil, ""},
+		{"gnome wayland", map[string]string{"XDG_CURRENT_DESKTOP": "ubuntu:GNOME", "XDG_SESSION_TYPE": "wayland"}, nil, "main.gnomeShellBackend"},
+		{"wayland display only", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, nil, "main.gnomeShellBackend"},
+		{"other wayland desktop", map[string]string{"XDG_CURRENT_DESKTOP": "COSMIC", "XDG_SESSION_TYPE": "wayland"}, []string{"wmctrl"}, ""},
+		{"x11 without display", map[string]string{"XDG_SESSION_TYPE": "x11"}, []string{"wmctrl"}, "main.wmctrlBackend"},
+		{"x11 unreachable display", map[string]string{"XDG_SESSION_TYPE": "x11", "DISPLAY": ":9999"}, []string{"wmctrl"}, "main.wmctrlBackend"},
+		{"x11 without wmctrl", map[string]string{"XDG_SESSION_TYPE": "x11"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv, lookPath := fakeEnv(tt.env, tt.bins...)
+			b, err := selectBackend(getenv, lookPath)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("selectBackend = %T, want an error", b)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectBackend: %v", err)
+			}
+			if got := fmt.Sprintf("%T", b); got != tt.want {
+				t.Errorf("selectBackend = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionType(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"XDG_SESSION_TYPE": "wayland"}, "wayland"},
+		{map[string]string{"XDG_SESSION_TYPE": "X11"}, "x11"},
+		{map[string]string{"XDG_SESSION_TYPE": "x11", "WAYLAND_DISPLAY": "wayland-0"}, "x11"},
+		{map[string]string{"XDG_SESSION_TYPE": "tty", "WAYLAND_DISPLAY": "wayland-0"}, "wayland"},
+		{map[string]string{}, "x11"},
+	}
+	for _, tt := range tests {
+		getenv, _ := fakeEnv(tt.env)
+		if got := sessionType(getenv); got != tt.want {
+			t.Errorf("sessionType(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeShellWindows(t *testing.T) {
+	got, err := decodeShellWindows(`[
+		{"id": "0x1a", "desktop": 0, "class": "firefox", "title": "Inbox: \"mail\""},
+		{"id": "0x2b", "desktop": 2, "class": "org.gnome.Terminal", "title": ""},
+		{"id": "0x3c", "desktop": -1, "class": "", "title": "sticky", "extra": true}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []windowInfo{
+		{ID: "0x1a", Desktop: 0, Class: "firefox", Title: `Inbox: "mail"`},
+		{ID: "0x2b", Desktop: 2, Class: "org.gnome.Terminal"},
+		{ID: "0x3c", Desktop: -1, Title: "sticky"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeShellWindows = %+v, want %+v", got, want)
+	}
+
+	if got, err := decodeShellWindows(`[]`); err != nil || len(got) != 0 {
+		t.Errorf("decodeShellWindows([]) = %+v, %v, want none", got, err)
+	}
+	for _, js := range []string{``, `{}`, `[{"id": 1}]`, `[{"desktop": "2"}]`, `not json`} {
+		_, err := decodeShellWindows(js)
+		if err == nil || !strings.HasPrefix(err.Error(), "gnome-shell:") {
+			t.Errorf("decodeShellWindows(%q) error = %v, want a gnome-shell error", js, err)
+		}
+	}
+}
+
+func TestI3TreeWindows(t *testing.T) {
+	got, err := i3TreeWindows([]byte(`{"id": 1, "type": "root", "nodes": [
+		{"id": 2, "type": "output", "name": "__i3", "nodes": [
+			{"id": 3, "type": "workspace", "name": "__i3_scratch", "num": -1, "floating_nodes": [
+				{"id": 4, "type": "floating_con", "nodes": [
+					{"id": 5, "type": "con", "name": "hidden", "window": 7, "window_properties": {"class": "Term", "instance": "term"}}
+				]}
+			]}
+		]},
+		{"id": 10, "type": "output", "name": "DP-1", "nodes": [
+			{"id": 11, "type": "workspace", "name": "1: Web", "num": 1, "nodes": [
+				{"id": 12, "type": "con", "name": "Inbox", "window": 41, "window_properties": {"class": "Firefox", "instance": "Navigator"}},
+				{"id": 13, "type": "con", "layout": "splitv", "nodes": [
+					{"id": 14, "type": "con", "name": "foot", "app_id": "foot"}
+				]}
+			]},
+			{"id": 20, "type": "workspace", "name": "3", "num": 3, "nodes": [], "floating_nodes": [
+				{"id": 21, "type": "floating_con", "sticky": true, "name": "pip", "app_id": "mpv"}
+			]}
+		]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []windowInfo{
+		{ID: "12", Desktop: 0, Class: "Navigator.Firefox", Title: "Inbox"},
+		{ID: "14", Desktop: 0, Class: "foot", Title: "foot"},
+		{ID: "21", Desktop: -1, Class: "mpv", Title: "pip"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("i3TreeWindows = %+v, want %+v", got, want)
+	}
+	if _, err := i3TreeWindows([]byte(`not json`)); err == nil {
+		t.Error("i3TreeWindows(not json) = nil error")
+	}
+}
+
+func TestBackendMissing(t *testing.T) {
+	sock := t.TempDir() + "/sway.sock"
+	if err := os.WriteFile(sock, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		bins []string
+		want bool // something is missing
+	}{
+		{"sway", map[string]string{"SWAYSOCK": sock}, nil, false},
+		{"sway", map[string]string{}, nil, true},
+		{"i3", map[string]string{"I3SOCK": sock + ".gone"}, nil, true},
+		{"hyprland", nil, []string{"hyprctl"}, false},
+		{"hyprland", nil, nil, true},
+		{"kde", nil, nil, true},
+		{"wmctrl", nil, []string{"wmctrl"}, false},
+		{"wmctrl", nil, nil, true},
+		{"gnome-shell", nil, nil, false},
+	}
+	for _, tt := range tests {
+		getenv, lookPath := fakeEnv(tt.env, tt.bins...)
+		if got := backendMissing(tt.name, getenv, lookPath); (got != "") != tt.want {
+			t.Errorf("backendMissing(%q, %v, %v) = %q, want missing %v", tt.name, tt.env, tt.bins, got, tt.want)
+		}
+	}
+}
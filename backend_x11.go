@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// -----------------------------------------------------------------------------
+// X11 (EWMH)
+// -----------------------------------------------------------------------------
+
+// x11Backend reads and switches workspaces through the EWMH root window
+// properties over a direct X connection, avoiding a wmctrl fork per call.
+// Count and dynamic-mode changes stay on gsettings like wmctrlBackend.
+type x11Backend struct {
+	gnomeSettings
+	conn *xgb.Conn
+	root xproto.Window
+}
+
+func newX11Backend(display string) (*x11Backend, error) {
+	// xgb logs stray errors to stderr, which would garble the TUI.
+	xgb.Logger = log.New(io.Discard, "", 0)
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		return nil, err
+	}
+	setup := xproto.Setup(conn)
+	return &x11Backend{conn: conn, root: setup.DefaultScreen(conn).Root}, nil
+}
+
+func (b *x11Backend) atom(name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(b.conn, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, err
+	}
+	if reply.Atom == xproto.AtomNone {
+		return 0, fmt.Errorf("%s is not supported by the window manager", name)
+	}
+	return reply.Atom, nil
+}
+
+// property reads the named property of win, whatever its type. A missing
+// property has an empty value.
+func (b *x11Backend) property(win xproto.Window, name string) (*xproto.GetPropertyReply, error) {
+	a, err := b.atom(name)
+	if err != nil {
+		return nil, err
+	}
+	return xproto.GetProperty(b.conn, false, win, a, xproto.GetPropertyTypeAny, 0, 1<<16).Reply()
+}
+
+// cardinal reads a single CARDINAL property from the root window.
+func (b *x11Backend) cardinal(name string) (int, error) {
+	reply, err := b.property(b.root, name)
+	if err != nil {
+		return 0, err
+	}
+	if reply.Format != 32 || len(reply.Value) < 4 {
+		return 0, fmt.Errorf("%s is not set", name)
+	}
+	return int(xgb.Get32(reply.Value)), nil
+}
+
+// sendMessage sends an EWMH client message about win to the root window,
+// the way pagers ask the window manager for changes.
+func (b *x11Backend) sendMessage(win xproto.Window, name string, data ...uint32) error {
+	a, err := b.atom(name)
+	if err != nil {
+		return err
+	}
+	var d [5]uint32
+	copy(d[:], data)
+	ev := xproto.ClientMessageEvent{
+		Format: 32,
+		Window: win,
+		Type:   a,
+		Data:   xproto.ClientMessageDataUnionData32New(d[:]),
+	}
+	mask := uint32(xproto.EventMaskSubstructureNotify | xproto.EventMaskSubstructureRedirect)
+	return xproto.SendEventChecked(b.conn, false, b.root, mask, string(ev.Bytes())).Check()
+}
+
+func (b *x11Backend) Count() (int, error) {
+	return b.cardinal("_NET_NUMBER_OF_DESKTOPS")
+}
+
+func (b *x11Backend) ActiveIndex() (int, error) {
+	idx, err := b.cardinal("_NET_CURRENT_DESKTOP")
+	if err != nil {
+		return -1, errors.New("no active workspace found")
+	}
+	return idx, nil
+}
+
+func (b *x11Backend) Switch(idx int) error {
+	return b.sendMessage(b.root, "_NET_CURRENT_DESKTOP", uint32(idx), uint32(xproto.TimeCurrentTime))
+}
+
+func parseWindowID(id string) (xproto.Window, error) {
+	win, err := strconv.ParseUint(id, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window id %q", id)
+	}
+	return xproto.Window(win), nil
+}
+
+// FocusWindow implements windowController with a _NET_ACTIVE_WINDOW
+// request.
+func (b *x11Backend) FocusWindow(id string) error {
+	win, err := parseWindowID(id)
+	if err != nil {
+		return err
+	}
+	return b.sendMessage(win, "_NET_ACTIVE_WINDOW", 2, uint32(xproto.TimeCurrentTime))
+}
+
+func (b *x11Backend) MoveWindow(id string, idx int) error {
+	win, err := parseWindowID(id)
+	if err != nil {
+		return err
+	}
+	return b.sendMessage(win, "_NET_WM_DESKTOP", uint32(idx), 2)
+}
+
+// MoveActiveWindow implements windowMover by asking for a new
+// _NET_WM_DESKTOP on the window named by _NET_ACTIVE_WINDOW.
+func (b *x11Backend) MoveActiveWindow(idx int) error {
+	r, err := b.property(b.root, "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return err
+	}
+	if len(r.Value) < 4 || xgb.Get32(r.Value) == 0 {
+		return errors.New("no focused window")
+	}
+	// Source indication 2 marks the request as coming from a pager.
+	return b.sendMessage(xproto.Window(xgb.Get32(r.Value)), "_NET_WM_DESKTOP", uint32(idx), 2)
+}
+
+// Windows implements windowLister from _NET_CLIENT_LIST, reporting the
+// same fields as `wmctrl -lx`.
+func (b *x11Backend) Windows() ([]windowInfo, error) {
+	reply, err := b.property(b.root, "_NET_CLIENT_LIST")
+	if err != nil {
+		return nil, err
+	}
+	var wins []windowInfo
+	for v := reply.Value; len(v) >= 4; v = v[4:] {
+		win := xproto.Window(xgb.Get32(v))
+		d := -1
+		if r, err := b.property(win, "_NET_WM_DESKTOP"); err == nil && len(r.Value) >= 4 {
+			if n := xgb.Get32(r.Value); n != 0xFFFFFFFF {
+				d = int(n)
+			}
+		}
+		wins = append(wins, windowInfo{
+			ID:      fmt.Sprintf("0x%08x", uint32(win)),
+			Desktop: d,
+			Class:   b.wmClass(win),
+			Title:   b.title(win),
+		})
+	}
+	return wins, nil
+}
+
+// wmClass returns WM_CLASS as "instance.Class".
+func (b *x11Backend) wmClass(win xproto.Window) string {
+	r, err := b.property(win, "WM_CLASS")
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.TrimRight(string(r.Value), "\x00"), "\x00")
+	return strings.Join(parts, ".")
+}
+
+// title prefers the UTF-8 _NET_WM_NAME over the legacy WM_NAME.
+func (b *x11Backend) title(win xproto.Window) string {
+	for _, name := range []string{"_NET_WM_NAME", "WM_NAME"} {
+		if r, err := b.property(win, name); err == nil && len(r.Value) > 0 {
+			return string(r.Value)
+		}
+	}
+	return ""
+}
+
+// Watch implements workspaceWatcher through PropertyNotify events on the
+// root window.
+func (b *x11Backend) Watch(stop <-chan struct{}, changed func()) error {
+	current, err := b.atom("_NET_CURRENT_DESKTOP")
+	if err != nil {
+		return err
+	}
+	number, err := b.atom("_NET_NUMBER_OF_DESKTOPS")
+	if err != nil {
+		return err
+	}
+	err = xproto.ChangeWindowAttributesChecked(b.conn, b.root, xproto.CwEventMask,
+		[]uint32{xproto.EventMaskPropertyChange}).Check()
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			ev, xerr := b.conn.WaitForEvent()
+			if ev == nil && xerr == nil {
+				errc <- errors.New("X connection closed")
+				return
+			}
+			if p, ok := ev.(xproto.PropertyNotifyEvent); ok && (p.Atom == current || p.Atom == number) {
+				changed()
+			}
+		}
+	}()
+	select {
+	case <-stop:
+		return nil
+	case err := <-errc:
+		return err
+	}
+}
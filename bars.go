@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// -----------------------------------------------------------------------------
+// Status bars
+// -----------------------------------------------------------------------------
+
+// runBar prints render's output once or, with listen, again on every
+// workspace change, for bars that read a line per update. A --format
+// template, as for list, replaces each workspace's label.
+func runBar(listen bool, format string, render func([]listEntry) (string, error)) error {
+	tmpl, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	show := func() error {
+		entries, err := fetchEntries()
+		if err != nil {
+			return err
+		}
+		if tmpl != nil {
+			for i := range entries {
+				if entries[i].label, err = entryLine(entries[i], tmpl); err != nil {
+					return err
+				}
+			}
+		}
+		out, err := render(entries)
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+		return nil
+	}
+	if !listen {
+		return show()
+	}
+	return followEvents(func(workspaceEvent) { _ = show() })
+}
+
+// barLabel is a workspace's name with its configured glyph icon in front,
+// or the label runBar rendered for it.
+func barLabel(e listEntry) string {
+	if e.label != "" {
+		return e.label
+	}
+	if icon := iconText(e.Icon); icon != "" {
+		return icon + " " + e.Name
+	}
+	return e.Name
+}
+
+// waybarOutput is a waybar custom module update (return-type json).
+type waybarOutput struct {
+	Text    string   `json:"text"`
+	Tooltip string   `json:"tooltip"`
+	Class   []string `json:"class"`
+}
+
+// waybarModule shows the active workspace, with every workspace in the
+// tooltip. Its classes are ws-<index> and, for a workspace with a color,
+// color-<color> ("color-89b4fa", "color-green"), for styling per workspace.
+// Waybar renders text and tooltip as Pango markup, so names are escaped.
+func waybarModule(entries []listEntry) (string, error) {
+	var out waybarOutput
+	var tip []string
+	for _, e := range entries {
+		line := escapeMarkup(fmt.Sprintf("[%s] %s", e.Label(), barLabel(e)))
+		if e.Note != "" {
+			line += " <i>" + escapeMarkup(e.Note) + "</i>"
+		}
+		if e.Active {
+			out.Text = escapeMarkup(barLabel(e))
+			out.Class = []string{fmt.Sprintf("ws-%d", e.Index)}
+			if e.Color != "" {
+				out.Class = append(out.Class, "color-"+strings.ToLower(strings.TrimPrefix(e.Color, "#")))
+			}
+			line += "  *"
+		}
+		tip = append(tip, line)
+	}
+	out.Tooltip = strings.Join(tip, "\n")
+	b, err := json.Marshal(out)
+	return string(b), err
+}
+
+// waybarClick handles the on-click/on-scroll actions of the waybar module:
+// left opens the menu, right goes back, scrolling steps through workspaces.
+func waybarClick(action string) error {
+	switch action {
+	case "left":
+		return runMenu("", nil)
+	case "right":
+		idx, err := recentWorkspace(1)
+		if err != nil {
+			return err
+		}
+		return switchWorkspace(idx)
+	case "scroll-up":
+		return stepWorkspace(-1, true, "", false)
+	case "scroll-down":
+		return stepWorkspace(1, true, "", false)
+	}
+	return fmt.Errorf("unknown action %q (supported: left, right, scroll-up, scroll-down)", action)
+}
+
+// hexColor returns a workspace color as #rrggbb for bars that take nothing
+// else, such as polybar and i3bar. Named colors ("green") are looked up as
+// the TUI does; colors that can't be converted give "".
+func hexColor(color string) string {
+	if color == "" {
+		return ""
+	}
+	rgb := tcell.GetColor(strings.ToLower(strings.TrimSpace(color))).Hex()
+	if rgb < 0 {
+		return ""
+	}
+	return fmt.Sprintf("#%06x", rgb)
+}
+
+// polybarModule renders every workspace with a click action switching to
+// it; the active one is underlined and colored, with theme.wofi_active
+// when it has no color of its own, as in the launcher menus.
+func polybarModule(entries []listEntry) (string, error) {
+	esc := strings.NewReplacer("%", "%%")
+	var parts []string
+	for _, e := range entries {
+		label := esc.Replace(barLabel(e))
+		color := hexColor(e.Color)
+		if e.Active {
+			if color == "" {
+				color = hexColor(currentTheme().WofiActive)
+			}
+			if color == "" {
+				label = fmt.Sprintf("%%{+u}%s%%{-u}", label)
+			} else {
+				label = fmt.Sprintf("%%{u%s}%%{+u}%%{F%s}%s%%{F-}%%{-u}", color, color, label)
+			}
+		} else if color != "" {
+			label = fmt.Sprintf("%%{F%s}%s%%{F-}", color, label)
+		}
+		parts = append(parts, fmt.Sprintf("%%{A1:gnav switch --index-base 1 %d:}%s%%{A}", e.Index, label))
+	}
+	return strings.Join(parts, "  "), nil
+}
+
+// ewwWorkspace is one workspace in `gnav eww` output, meant to be read by
+// an eww deflisten and iterated over with for.
+type ewwWorkspace struct {
+	Index   int    `json:"index"`
+	Label   string `json:"label"`
+	Name    string `json:"name"`
+	Icon    string `json:"icon"`
+	Color   string `json:"color"`
+	Active  bool   `json:"active"`
+	Windows int    `json:"windows"`
+}
+
+// ewwModule renders all workspaces as one JSON array per line.
+func ewwModule(entries []listEntry) (string, error) {
+	out := make([]ewwWorkspace, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, ewwWorkspace{
+			Index:   e.Index,
+			Label:   e.Label(),
+			Name:    e.Name,
+			Icon:    e.Icon,
+			Color:   e.Color,
+			Active:  e.Active,
+			Windows: e.Windows,
+		})
+	}
+	b, err := json.Marshal(out)
+	return string(b), err
+}
+
+// i3barBlock is the i3bar protocol block read by i3blocks (format=json)
+// and i3status-rust custom blocks.
+type i3barBlock struct {
+	FullText  string `json:"full_text"`
+	ShortText string `json:"short_text"`
+	Color     string `json:"color,omitempty"`
+}
+
+// i3barModule renders the active workspace as one i3bar block. i3bar only
+// takes #rrggbb colors, so named ones are converted and others left out.
+func i3barModule(entries []listEntry) (string, error) {
+	for _, e := range entries {
+		if e.Active {
+			b, err := json.Marshal(i3barBlock{
+				FullText:  barLabel(e),
+				ShortText: e.Label(),
+				Color:     hexColor(e.Color),
+			})
+			return string(b), err
+		}
+	}
+	return "", errors.New("no active workspace found")
+}
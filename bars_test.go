@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestHexColor(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"#89b4fa", "#89b4fa"},
+		{"#89B4FA", "#89b4fa"},
+		{"green", "#008000"},
+		{"Red", "#ff0000"},
+		{"cornflowerblue", "#6495ed"},
+		{"not-a-color", ""},
+		{"#12", ""},
+	}
+	for _, tt := range tests {
+		if got := hexColor(tt.in); got != tt.want {
+			t.Errorf("hexColor(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPolybarModuleColors(t *testing.T) {
+	setConfig(t, &Config{Theme: Theme{Preset: "none"}})
+	out, err := polybarModule([]listEntry{
+		{Index: 1, Name: "Web", Color: "green"},
+		{Index: 2, Name: "Code", Color: "bogus", Active: true},
+		{Index: 3, Name: "Chat", Color: "#89b4fa"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "%{A1:gnav switch --index-base 1 1:}%{F#008000}Web%{F-}%{A}  " +
+		"%{A1:gnav switch --index-base 1 2:}%{+u}Code%{-u}%{A}  " +
+		"%{A1:gnav switch --index-base 1 3:}%{F#89b4fa}Chat%{F-}%{A}"
+	if out != want {
+		t.Errorf("polybarModule =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestI3barModuleColors(t *testing.T) {
+	setConfig(t, &Config{})
+	tests := []struct{ color, want string }{
+		{"green", `{"full_text":"Web","short_text":"1","color":"#008000"}`},
+		{"#89b4fa", `{"full_text":"Web","short_text":"1","color":"#89b4fa"}`},
+		{"bogus", `{"full_text":"Web","short_text":"1"}`},
+	}
+	for _, tt := range tests {
+		out, err := i3barModule([]listEntry{{Index: 1, Name: "Web", Color: tt.color, Active: true}})
+		if err != nil || out != tt.want {
+			t.Errorf("i3barModule(color %q) = %s, %v, want %s", tt.color, out, err, tt.want)
+		}
+	}
+}
+
+func TestBarFormat(t *testing.T) {
+	setConfig(t, &Config{})
+	tmpl, err := parseFormat("{{.Label}}:{{.Name}} ({{.Windows}})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := listEntry{Index: 2, Name: "Code", Windows: 3, Active: true}
+	if e.label, err = entryLine(e, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"full_text":"2:Code (3)","short_text":"2"}`
+	if out, err := i3barModule([]listEntry{e}); err != nil || out != want {
+		t.Errorf("i3barModule = %s, %v, want %s", out, err, want)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// Config validation
+// -----------------------------------------------------------------------------
+
+// maxNameLength is the longest workspace name that still fits comfortably
+// in bars and launcher rows.
+const maxNameLength = 40
+
+// configProblem is one finding of checkConfigData. Line is 1-based, or 0
+// when the position is unknown (TOML files are checked after conversion).
+type configProblem struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (p configProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", filepath.Base(p.File), p.Line, p.Msg)
+	}
+	return fmt.Sprintf("%s: %s", filepath.Base(p.File), p.Msg)
+}
+
+// configChecker walks a parsed config against the Config struct, recording
+// problems and the line of every key path it sees (e.g.
+// "workspaces[2].name") for the checks that run on the decoded values.
+type configChecker struct {
+	file     string
+	noLines  bool
+	lines    map[string]int
+	problems []configProblem
+}
+
+func (c *configChecker) add(line int, format string, args ...interface{}) {
+	if c.noLines {
+		line = 0
+	}
+	c.problems = append(c.problems, configProblem{c.file, line, fmt.Sprintf(format, args...)})
+}
+
+var (
+	yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+	tomlErrorLine = regexp.MustCompile(`^toml: line \d+[^:]*: (.*)$`)
+)
+
+// checkConfigData validates the contents b of the config file path: syntax,
+// unknown keys, values of the wrong type, and then the values themselves.
+func checkConfigData(path string, b []byte) []configProblem {
+	c := &configChecker{file: path, lines: map[string]int{}}
+	if configFormat(path) == "toml" {
+		var m map[string]interface{}
+		if err := toml.Unmarshal(b, &m); err != nil {
+			var pe toml.ParseError
+			if m := tomlErrorLine.FindStringSubmatch(err.Error()); errors.As(err, &pe) && m != nil {
+				c.add(pe.Position.Line, "%s", m[1])
+			} else {
+				c.add(0, "%v", err)
+			}
+			return c.problems
+		}
+		y, err := yaml.Marshal(m)
+		if err != nil {
+			c.add(0, "%v", err)
+			return c.problems
+		}
+		b, c.noLines = y, true
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			c.add(line, "%s", m[2])
+		} else {
+			c.add(0, "%s", strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+		return c.problems
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	c.checkNode(doc.Content[0], reflect.TypeOf(Config{}), "")
+
+	var parsed Config
+	if len(c.problems) == 0 && yaml.Unmarshal(b, &parsed) == nil {
+		c.checkValues(&parsed)
+		sort.SliceStable(c.problems, func(i, j int) bool { return c.problems[i].Line < c.problems[j].Line })
+	}
+	return c.problems
+}
+
+// checkNode checks n against type t; path names n in messages.
+func (c *configChecker) checkNode(n *yaml.Node, t reflect.Type, path string) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	c.lines[path] = n.Line
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			c.add(n.Line, "%s should be a mapping of keys to values", describePath(path))
+			return
+		}
+		fields := yamlFields(t)
+		seen := map[string]int{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			if first, dup := seen[key.Value]; dup {
+				c.add(key.Line, "%s is set twice (first on line %d)", describePath(joinPath(path, key.Value)), first)
+				continue
+			}
+			seen[key.Value] = key.Line
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", key.Value)
+				if path != "" {
+					msg += " in " + path
+				}
+				if s := closestKey(key.Value, fields); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				c.add(key.Line, "%s", msg)
+				continue
+			}
+			c.checkNode(val, field.Type, joinPath(path, key.Value))
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			c.add(n.Line, "%s should be a list", describePath(path))
+			return
+		}
+		for i, item := range n.Content {
+			c.checkNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			c.add(n.Line, "%s should be a mapping of keys to values", describePath(path))
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			c.checkNode(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value))
+		}
+	default:
+		if n.Kind != yaml.ScalarNode {
+			c.add(n.Line, "%s should be %s", describePath(path), typeDescription(t))
+			return
+		}
+		if err := n.Decode(reflect.New(t).Interface()); err != nil {
+			c.add(n.Line, "%s should be %s, not %q", describePath(path), typeDescription(t), n.Value)
+		}
+	}
+}
+
+// checkValues looks at what the decoded settings mean: workspace names that
+// will misbehave, and settings outside their allowed values.
+func (c *configChecker) checkValues(parsed *Config) {
+	byName := map[string]int{}
+	for i, w := range parsed.Workspaces {
+		path := fmt.Sprintf("workspaces[%d].name", i)
+		line := c.lines[path]
+		if line == 0 {
+			line = c.lines[fmt.Sprintf("workspaces[%d]", i)]
+		}
+		n := i + 1
+		name := strings.TrimSpace(w.Name)
+		if name == "" {
+			c.add(line, "workspace %d has no name ('gnav config repair' fills it in)", n)
+			continue
+		}
+		if first, dup := byName[strings.ToLower(name)]; dup {
+			c.add(line, "workspace %d is named %q like workspace %d, so switching by name can't tell them apart", n, w.Name, first)
+		} else {
+			byName[strings.ToLower(name)] = n
+		}
+		if l := utf8.RuneCountInString(w.Name); l > maxNameLength {
+			c.add(line, "workspace %d's name is %d characters long; keep it under %d to fit bars and menus", n, l, maxNameLength)
+		}
+		if strings.IndexFunc(w.Name, unicode.IsControl) >= 0 {
+			c.add(line, "workspace %d's name contains a line break or control character, which splits menu rows", n)
+		}
+		if tag := markupTag.FindString(w.Name); tag != "" {
+			c.add(line, "workspace %d's name contains markup %s, which is shown literally rather than styled", n, tag)
+		}
+	}
+
+	for i, r := range parsed.Rules {
+		if _, err := compileRule(r); err != nil {
+			c.add(c.lines[fmt.Sprintf("rules[%d]", i)], "rule %d: %v", i+1, err)
+		}
+	}
+
+	if parsed.NotifyFormat != "" {
+		if _, err := template.New("notify_format").Parse(parsed.NotifyFormat); err != nil {
+			c.add(c.lines["notify_format"], "notify_format is not a valid template: %v", err)
+		}
+	}
+
+	oneOf := func(key, value string, allowed ...string) {
+		if value == "" {
+			return
+		}
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		c.add(c.lines[key], "%s is %q; expected one of %s", key, value, strings.Join(allowed, ", "))
+	}
+	if parsed.Count < 0 {
+		c.add(c.lines["count"], "count is %d; expected a positive number", parsed.Count)
+	}
+	if parsed.IndexBase != nil && *parsed.IndexBase != 0 && *parsed.IndexBase != 1 {
+		c.add(c.lines["index_base"], "index_base is %d; expected 0 or 1", *parsed.IndexBase)
+	}
+	oneOf("index_style", parsed.IndexStyle, "arabic", "roman", "letters")
+	oneOf("menu_order", strings.ToLower(parsed.MenuOrder), menuOrders...)
+	backendNames := []string{"auto"}
+	for name := range backends {
+		backendNames = append(backendNames, name)
+	}
+	sort.Strings(backendNames[1:])
+	oneOf("backend", parsed.Backend, backendNames...)
+	oneOf("name_sync", strings.ToLower(parsed.NameSync), nameSyncOff, nameSyncPush, nameSyncPull, nameSyncMerge)
+	oneOf("name_sync_prefer", parsed.NameSyncPrefer, "gnav", "gnome")
+	if parsed.HookTimeout != "" {
+		if d, err := time.ParseDuration(parsed.HookTimeout); err != nil || d <= 0 {
+			c.add(c.lines["hook_timeout"], "hook_timeout is %q; expected a duration such as \"5s\"", parsed.HookTimeout)
+		}
+	}
+	presets := make([]string, 0, len(themePresets))
+	for name := range themePresets {
+		presets = append(presets, name)
+	}
+	sort.Strings(presets)
+	oneOf("theme.preset", parsed.Theme.Preset, presets...)
+}
+
+// yamlFields maps the yaml key of each of t's fields to the field.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := strings.Split(f.Tag.Get("yaml"), ",")[0]; name != "" && name != "-" {
+			fields[name] = f
+		}
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "the config"
+	}
+	return path
+}
+
+func typeDescription(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64:
+		return "a whole number"
+	case reflect.String:
+		return "a string"
+	}
+	return t.String()
+}
+
+// closestKey suggests the known key within two edits of key, if any.
+func closestKey(key string, fields map[string]reflect.StructField) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDist || d == bestDist && best != "" && name < best {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// Config file formats
+// -----------------------------------------------------------------------------
+
+// The config can be YAML, TOML or JSON, picked by the file's extension.
+// There is one model, Config, with YAML tags: JSON is read as the YAML
+// subset it is, and the other formats go through a generic map so the key
+// names stay the same in all three.
+var configExtensions = []string{".yaml", ".yml", ".toml", ".json"}
+
+func isConfigExtension(ext string) bool {
+	for _, e := range configExtensions {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return "toml"
+	case ".json":
+		return "json"
+	}
+	return "yaml"
+}
+
+// findConfigFile returns path if it exists, else the first file next to it
+// with the same base name and another config extension, else path itself.
+func findConfigFile(path string) string {
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, ext := range configExtensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return path
+}
+
+// decodeConfig parses b, read from path, into v: a Config, or another type
+// described with yaml tags.
+func decodeConfig(path string, b []byte, v interface{}) error {
+	return decodeFormat(configFormat(path), b, v)
+}
+
+func decodeFormat(format string, b []byte, v interface{}) error {
+	if format == "toml" {
+		var m map[string]interface{}
+		if err := toml.Unmarshal(b, &m); err != nil {
+			return err
+		}
+		y, err := yaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		b = y
+	}
+	return yaml.Unmarshal(b, v)
+}
+
+// encodeConfig renders v in the format of path.
+func encodeConfig(path string, v interface{}) ([]byte, error) {
+	return encodeFormat(configFormat(path), v)
+}
+
+func encodeFormat(format string, v interface{}) ([]byte, error) {
+	y, err := yaml.Marshal(v)
+	if err != nil || format == "yaml" {
+		return y, err
+	}
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(y, &m); err != nil {
+		return nil, err
+	}
+	if format == "json" {
+		b, err := json.MarshalIndent(m, "", "  ")
+		return append(b, '\n'), err
+	}
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	err = enc.Encode(m)
+	return buf.Bytes(), err
+}
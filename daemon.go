@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// -----------------------------------------------------------------------------
+// Workspace events
+// -----------------------------------------------------------------------------
+
+// workspaceWatcher is implemented by backends that can report workspace
+// changes as they happen. Watch calls changed on every relevant change until
+// stop is closed; changed may fire when nothing visible changed.
+type workspaceWatcher interface {
+	Watch(stop <-chan struct{}, changed func()) error
+}
+
+// watchWorkspaces calls changed whenever the workspaces may have changed,
+// using backend events where available and polling otherwise.
+func watchWorkspaces(stop <-chan struct{}, changed func()) {
+	if w, ok := backend.(workspaceWatcher); ok {
+		if err := w.Watch(stop, changed); err == nil {
+			return
+		}
+	}
+	count, _ := getSystemWorkspaceCount()
+	active, _ := getActiveWorkspaceIndex()
+	pollWorkspaces(stop, count, active, changed)
+}
+
+// workspaceEvent is one line of the watch stream. Index is 1-based.
+type workspaceEvent struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Count   int    `json:"count"`
+	Dynamic bool   `json:"dynamic"`
+}
+
+// cfgMu serialises config access between the daemon's goroutines.
+var cfgMu sync.Mutex
+
+func currentEvent() (workspaceEvent, error) {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	reloadConfig()
+	count, err := getSystemWorkspaceCount()
+	if err != nil {
+		return workspaceEvent{}, err
+	}
+	active, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return workspaceEvent{}, err
+	}
+	dyn, _ := getDynamic()
+	return workspaceEvent{
+		Index:   active + 1,
+		Name:    displayName(active, count, dyn),
+		Count:   count,
+		Dynamic: dyn,
+	}, nil
+}
+
+// watchEvents sends the current state and then every distinct change to
+// emit, until stop is closed. Config edits count too, as they may rename
+// the active workspace.
+func watchEvents(stop <-chan struct{}, emit func(workspaceEvent)) {
+	var mu sync.Mutex
+	last, err := currentEvent()
+	if err == nil {
+		emit(last)
+	}
+	update := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		ev, err := currentEvent()
+		if err != nil || ev == last {
+			return
+		}
+		last = ev
+		emit(ev)
+	}
+	go func() { _ = watchConfig(stop, update) }()
+	watchWorkspaces(stop, update)
+}
+
+// printEvent writes ev as a `list`-style line, or as JSON.
+func printEvent(ev workspaceEvent, asJSON bool) {
+	if asJSON {
+		_ = json.NewEncoder(os.Stdout).Encode(ev)
+		return
+	}
+	fmt.Printf("[%s] %s\n", formatIndex(ev.Index-1), ev.Name)
+}
+
+// -----------------------------------------------------------------------------
+// Daemon
+// -----------------------------------------------------------------------------
+
+// The daemon speaks newline-delimited JSON on a unix socket: the client
+// sends one daemonRequest and reads responses until it hangs up. watch
+// streams workspaceEvents; switch, rename, create and list answer once.
+// Config is the client's config file; the daemon turns the request down
+// with ConfigMismatch when it runs with another, and the client then does
+// the work itself.
+type daemonRequest struct {
+	Cmd    string   `json:"cmd"`
+	Args   []string `json:"args,omitempty"`
+	Config string   `json:"config,omitempty"`
+}
+
+type daemonError struct {
+	Error          string `json:"error"`
+	ConfigMismatch bool   `json:"config_mismatch,omitempty"`
+}
+
+// configPinned is set when --config, --profile or GNAV_CONFIG chose the
+// config file. Otherwise the daemon follows `gnav profile use`.
+var configPinned bool
+
+// absConfigFile is configFile as an absolute path, for comparing the
+// daemon's with a client's.
+func absConfigFile() string {
+	if abs, err := filepath.Abs(configFile); err == nil {
+		return abs
+	}
+	return configFile
+}
+
+// reloadConfig brings the daemon's config up to date: the saved profile,
+// unless the config file was pinned, and the file's contents. The caller
+// holds cfgMu.
+func reloadConfig() {
+	if !configPinned {
+		old := configFile
+		if selectProfile(savedProfile()) == nil && configFile != old {
+			_ = loadConfig()
+			return
+		}
+	}
+	if configChangedOnDisk() {
+		_ = loadConfig()
+	}
+}
+
+// sameConfig reports whether a request made with config may be served
+// with the daemon's config. Requests from the daemon's own D-Bus service
+// carry none.
+func sameConfig(config string) bool {
+	if config == "" {
+		return true
+	}
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	reloadConfig()
+	return config == absConfigFile()
+}
+
+func socketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gnav.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gnav-%d.sock", os.Getuid()))
+}
+
+// daemon fans workspace events out to the clients watching them.
+type daemon struct {
+	mu       sync.Mutex
+	last     workspaceEvent
+	watchers map[chan workspaceEvent]struct{}
+}
+
+func (d *daemon) publish(ev workspaceEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = ev
+	for ch := range d.watchers {
+		select {
+		case ch <- ev:
+		default:
+			// A client too slow to keep up is dropped rather than
+			// holding back everyone else.
+			delete(d.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (d *daemon) subscribe() (chan workspaceEvent, workspaceEvent) {
+	ch := make(chan workspaceEvent, 16)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watchers[ch] = struct{}{}
+	return ch, d.last
+}
+
+func (d *daemon) unsubscribe(ch chan workspaceEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.watchers[ch]; ok {
+		delete(d.watchers, ch)
+		close(ch)
+	}
+}
+
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	enc := json.NewEncoder(conn)
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var req daemonRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		_ = enc.Encode(daemonError{Error: "bad request: " + err.Error()})
+		return
+	}
+	if !sameConfig(req.Config) {
+		_ = enc.Encode(daemonError{Error: "daemon uses another config file", ConfigMismatch: true})
+		return
+	}
+	switch req.Cmd {
+	case "watch":
+		ch, last := d.subscribe()
+		defer d.unsubscribe(ch)
+		if last.Count > 0 {
+			if enc.Encode(last) != nil {
+				return
+			}
+		}
+		for ev := range ch {
+			if enc.Encode(ev) != nil {
+				return
+			}
+		}
+	case "switch", "rename", "create", "list":
+		reply, err := d.handle(req)
+		if err != nil {
+			_ = enc.Encode(daemonError{Error: err.Error()})
+			return
+		}
+		_ = enc.Encode(reply)
+	default:
+		_ = enc.Encode(daemonError{Error: fmt.Sprintf("unknown command %q", req.Cmd)})
+	}
+}
+
+// handle runs a one-shot command against the daemon's config. Indices are
+// 1-based.
+func (d *daemon) handle(req daemonRequest) (interface{}, error) {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	reloadConfig()
+	switch req.Cmd {
+	case "switch":
+		if len(req.Args) != 1 {
+			return nil, errors.New("switch: expected an index")
+		}
+		idx, err := strconv.Atoi(req.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return struct{}{}, switchWorkspace(idx)
+	case "rename":
+		if len(req.Args) != 2 {
+			return nil, errors.New("rename: expected an index and a name")
+		}
+		idx, err := strconv.Atoi(req.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return struct{}{}, renameLocal(idx, req.Args[1])
+	case "create":
+		if len(req.Args) < 1 || len(req.Args) > 2 {
+			return nil, errors.New("create: expected a count and optionally \"static\"")
+		}
+		n, err := strconv.Atoi(req.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return struct{}{}, createWorkspaces(n, len(req.Args) == 2 && req.Args[1] == "static")
+	default:
+		count, err := getSystemWorkspaceCount()
+		if err != nil {
+			return nil, err
+		}
+		active, _ := getActiveWorkspaceIndex()
+		dyn, _ := getDynamic()
+		counts, _ := windowCounts()
+		return listEntries(count, active, dyn, counts), nil
+	}
+}
+
+// runDaemon listens on socketPath and, when a session bus is available, on
+// D-Bus, and streams workspace events to clients until interrupted.
+func runDaemon() error {
+	path := socketPath()
+	if daemonRunning() {
+		return fmt.Errorf("daemon already running on %s", path)
+	}
+	// Nothing answered, so any socket file left is stale.
+	_ = os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		<-sigs
+		close(stop)
+		ln.Close()
+	}()
+
+	d := &daemon{watchers: make(map[chan workspaceEvent]struct{})}
+	// Switches made without gnav still count for `gnav back` and still
+	// change the input source and focus mode, and are announced.
+	notifier := &switchNotifier{}
+	lastIndex := 0
+	publish := func(ev workspaceEvent) {
+		recordVisit(ev.Index)
+		cfgMu.Lock()
+		errs := []error{applyInputSource(ev.Index), applyFocusMode(ev.Index)}
+		if cfg.NotifyOnSwitch && lastIndex != 0 && ev.Index != lastIndex {
+			errs = append(errs, notifier.notify(ev.Index))
+		}
+		lastIndex = ev.Index
+		cfgMu.Unlock()
+		for _, err := range errs {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "gnav:", err)
+			}
+		}
+		d.publish(ev)
+	}
+	if svc, err := exportDBus(d); err != nil {
+		fmt.Fprintln(os.Stderr, "gnav: D-Bus service disabled:", err)
+	} else {
+		defer svc.conn.Close()
+		local := publish
+		publish = func(ev workspaceEvent) {
+			local(ev)
+			svc.activeChanged(ev)
+		}
+	}
+	go watchEvents(stop, publish)
+	go autoNameLoop(stop)
+	go rulesLoop(stop)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-stop:
+				// Leave banners as they were before a focus workspace.
+				return restoreBanners()
+			default:
+				return err
+			}
+		}
+		go d.serve(conn)
+	}
+}
+
+// daemonRunning reports whether a daemon is listening on socketPath.
+func daemonRunning() bool {
+	conn, err := net.Dial("unix", socketPath())
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// dialDaemon connects to a running daemon and sends req, marked with this
+// process's config file.
+func dialDaemon(req daemonRequest) (net.Conn, error) {
+	conn, err := net.Dial("unix", socketPath())
+	if err != nil {
+		return nil, err
+	}
+	req.Config = absConfigFile()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// callDaemon sends req to a running daemon and decodes its reply into out,
+// which may be nil. ok is false when no daemon is listening or it uses
+// another config file, so the caller can do the work itself.
+func callDaemon(req daemonRequest, out interface{}) (ok bool, err error) {
+	conn, err := dialDaemon(req)
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+	var raw json.RawMessage
+	if err := json.NewDecoder(conn).Decode(&raw); err != nil {
+		return true, errors.New("daemon connection closed")
+	}
+	var de daemonError
+	if json.Unmarshal(raw, &de) == nil && de.Error != "" {
+		if de.ConfigMismatch {
+			return false, nil
+		}
+		return true, errors.New(de.Error)
+	}
+	if out == nil {
+		return true, nil
+	}
+	return true, json.Unmarshal(raw, out)
+}
+
+// followEvents calls emit with the current state and on every change. It
+// follows the daemon's stream when one is running with the same config
+// file and watches in-process otherwise; it only returns if the daemon
+// goes away.
+func followEvents(emit func(workspaceEvent)) error {
+	conn, err := dialDaemon(daemonRequest{Cmd: "watch"})
+	if err != nil {
+		watchEvents(nil, emit)
+		return nil
+	}
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return errors.New("daemon connection closed")
+		}
+		var de daemonError
+		if json.Unmarshal(raw, &de) == nil && de.Error != "" {
+			if de.ConfigMismatch {
+				conn.Close()
+				watchEvents(nil, emit)
+				return nil
+			}
+			return errors.New(de.Error)
+		}
+		var ev workspaceEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return err
+		}
+		emit(ev)
+	}
+}
+
+// runWatch prints a line per workspace change.
+func runWatch(asJSON bool) error {
+	return followEvents(func(ev workspaceEvent) { printEvent(ev, asJSON) })
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// -----------------------------------------------------------------------------
+// D-Bus service
+// -----------------------------------------------------------------------------
+
+// The daemon also serves org.gnav.Workspaces on the session bus, so shell
+// extensions and other tools can drive gnav without spawning it. Indices
+// are 1-based, as on the command line.
+const (
+	dbusName  = "org.gnav.Workspaces"
+	dbusPath  = dbus.ObjectPath("/org/gnav/Workspaces")
+	dbusIface = "org.gnav.Workspaces"
+)
+
+const dbusIntrospection = `
+<node>
+	<interface name="` + dbusIface + `">
+		<method name="List">
+			<arg direction="out" type="a(usb)" name="workspaces"/>
+		</method>
+		<method name="Switch">
+			<arg direction="in" type="u" name="index"/>
+		</method>
+		<method name="Rename">
+			<arg direction="in" type="u" name="index"/>
+			<arg direction="in" type="s" name="name"/>
+		</method>
+		<method name="Create">
+			<arg direction="in" type="u" name="count"/>
+		</method>
+		<signal name="ActiveChanged">
+			<arg type="u" name="index"/>
+			<arg type="s" name="name"/>
+		</signal>
+	</interface>` + introspect.IntrospectDataString + `</node>`
+
+// dbusWorkspace is one entry of List, marshalled as (usb).
+type dbusWorkspace struct {
+	Index  uint32
+	Name   string
+	Active bool
+}
+
+type dbusService struct {
+	conn *dbus.Conn
+	d    *daemon
+
+	mu   sync.Mutex
+	last workspaceEvent
+}
+
+// exportDBus claims dbusName on the session bus and serves the daemon's
+// commands there.
+func exportDBus(d *daemon) (*dbusService, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	s := &dbusService{conn: conn, d: d}
+	if err := conn.Export(s, dbusPath, dbusIface); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Export(introspect.Introspectable(dbusIntrospection), dbusPath,
+		"org.freedesktop.DBus.Introspectable"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := exportSearchProvider(conn, d); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := exportKRunner(conn, d); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return nil, fmt.Errorf("%s is already owned on the session bus", dbusName)
+	}
+	return s, nil
+}
+
+func (s *dbusService) call(req daemonRequest) (interface{}, *dbus.Error) {
+	reply, err := s.d.handle(req)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	return reply, nil
+}
+
+func (s *dbusService) List() ([]dbusWorkspace, *dbus.Error) {
+	reply, derr := s.call(daemonRequest{Cmd: "list"})
+	if derr != nil {
+		return nil, derr
+	}
+	var out []dbusWorkspace
+	for _, e := range reply.([]listEntry) {
+		out = append(out, dbusWorkspace{uint32(e.Index), e.Name, e.Active})
+	}
+	return out, nil
+}
+
+func (s *dbusService) Switch(index uint32) *dbus.Error {
+	_, derr := s.call(daemonRequest{Cmd: "switch", Args: []string{strconv.Itoa(int(index))}})
+	return derr
+}
+
+func (s *dbusService) Rename(index uint32, name string) *dbus.Error {
+	_, derr := s.call(daemonRequest{Cmd: "rename", Args: []string{strconv.Itoa(int(index)), name}})
+	return derr
+}
+
+func (s *dbusService) Create(count uint32) *dbus.Error {
+	_, derr := s.call(daemonRequest{Cmd: "create", Args: []string{strconv.Itoa(int(count))}})
+	return derr
+}
+
+// activeChanged emits ActiveChanged when ev moves to another workspace or
+// the active one is renamed.
+func (s *dbusService) activeChanged(ev workspaceEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ev.Index == s.last.Index && ev.Name == s.last.Name {
+		return
+	}
+	s.last = ev
+	_ = s.conn.Emit(dbusPath, dbusIface+".ActiveChanged", uint32(ev.Index), ev.Name)
+}
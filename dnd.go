@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Focus workspaces
+// -----------------------------------------------------------------------------
+
+// On a focus workspace the daemon turns GNOME's notification banners off,
+// and turns them back to what they were once another workspace is active.
+// The setting it found is kept in bannersRestoreFile while banners are off,
+// so a restarted daemon still puts it back.
+
+const notificationsSchema = "org.gnome.desktop.notifications"
+
+var bannersRestoreFile = filepath.Join(filepath.Dir(historyFile), "banners-restore")
+
+func getShowBanners() (string, error) {
+	out, err := exec.Command("gsettings", "get", notificationsSchema, "show-banners").Output()
+	if err != nil {
+		return "", fmt.Errorf("gsettings: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func setShowBanners(value string) error {
+	out, err := exec.Command("gsettings", "set", notificationsSchema, "show-banners", value).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("gsettings: %v: %s", err, msg)
+		}
+		return fmt.Errorf("gsettings: %v", err)
+	}
+	return nil
+}
+
+// applyFocusMode silences banners when the 1-based workspace idx is a
+// focus workspace and restores them when it isn't.
+func applyFocusMode(idx int) error {
+	if !isGNOME(os.Getenv) {
+		return nil
+	}
+	if !workspaceMeta(idx - 1).Focus {
+		return restoreBanners()
+	}
+	if _, err := os.Stat(bannersRestoreFile); err == nil {
+		return nil
+	}
+	prev, err := getShowBanners()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(bannersRestoreFile), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(bannersRestoreFile, []byte(prev+"\n"), 0644); err != nil {
+		return err
+	}
+	return setShowBanners("false")
+}
+
+// restoreBanners puts back the show-banners setting a focus workspace
+// replaced, if any.
+func restoreBanners() error {
+	b, err := os.ReadFile(bannersRestoreFile)
+	if err != nil {
+		return nil
+	}
+	prev := strings.TrimSpace(string(b))
+	if prev != "true" && prev != "false" {
+		prev = "true"
+	}
+	if err := setShowBanners(prev); err != nil {
+		return err
+	}
+	return os.Remove(bannersRestoreFile)
+}
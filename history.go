@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofrs/flock"
+)
+
+// -----------------------------------------------------------------------------
+// Workspace history
+// -----------------------------------------------------------------------------
+
+// stateDir is the gnav directory under $XDG_STATE_HOME, or ~/.local/state
+// without it. The history and the other files gnav keeps between runs live
+// there.
+func stateDir(getenv func(string) string) string {
+	dir := getenv("XDG_STATE_HOME")
+	if dir == "" {
+		dir = filepath.Join(getenv("HOME"), ".local", "state")
+	}
+	return filepath.Join(dir, "gnav")
+}
+
+// historyFile lists recently active workspaces, most recent first, one
+// 1-based index per line. It outlives single invocations so `gnav back`
+// works across separate key presses.
+var historyFile = filepath.Join(stateDir(os.Getenv), "history")
+
+const historyLen = 10
+
+func loadHistory() []int {
+	b, err := os.ReadFile(historyFile)
+	if err != nil {
+		return nil
+	}
+	var hist []int
+	for _, f := range strings.Fields(string(b)) {
+		if n, err := strconv.Atoi(f); err == nil && n >= 1 {
+			hist = append(hist, n)
+		}
+	}
+	return hist
+}
+
+func saveHistory(hist []int) error {
+	if err := os.MkdirAll(filepath.Dir(historyFile), 0755); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, n := range hist {
+		b.WriteString(strconv.Itoa(n))
+		b.WriteByte('\n')
+	}
+	// Write and rename so the daemon and the CLI never see half a file.
+	tmp := historyFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, historyFile)
+}
+
+// lockHistory takes the advisory lock that serialises history updates, as
+// the daemon and key-bound commands may record visits at the same time.
+func lockHistory() (*flock.Flock, error) {
+	if err := os.MkdirAll(filepath.Dir(historyFile), 0755); err != nil {
+		return nil, err
+	}
+	lock := flock.New(historyFile + ".lock")
+	return lock, lock.Lock()
+}
+
+// pushHistory moves idx to the front of hist, keeping it within historyLen.
+func pushHistory(hist []int, idx int) []int {
+	out := []int{idx}
+	for _, n := range hist {
+		if n != idx && len(out) < historyLen {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// recordVisit notes that the 1-based workspace idx became active, in the
+// history and in the switch log.
+func recordVisit(idx int) {
+	lock, err := lockHistory()
+	if err != nil {
+		return
+	}
+	defer lock.Unlock()
+	hist := loadHistory()
+	if len(hist) > 0 && hist[0] == idx {
+		return
+	}
+	_ = saveHistory(pushHistory(hist, idx))
+	logSwitch(idx)
+}
+
+// remapHistory renumbers the history after workspaces moved: to maps old
+// 0-based positions to new ones, and entries it maps below 0 are dropped.
+func remapHistory(to func(int) int) error {
+	lock, err := lockHistory()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	seen := make(map[int]bool)
+	var hist []int
+	for _, n := range loadHistory() {
+		if m := to(n-1) + 1; m >= 1 && !seen[m] {
+			seen[m] = true
+			hist = append(hist, m)
+		}
+	}
+	return saveHistory(hist)
+}
+
+// recentWorkspace returns the nth most recently visited 1-based workspace
+// other than the active one; n=1 is the previous workspace.
+func recentWorkspace(n int) (int, error) {
+	if n < 1 {
+		return 0, fmt.Errorf("invalid position: %d", n)
+	}
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return 0, err
+	}
+	cur, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return 0, err
+	}
+	left := n
+	for _, h := range loadHistory() {
+		if h == cur+1 || h > sc {
+			continue
+		}
+		if left--; left == 0 {
+			return h, nil
+		}
+	}
+	if n == 1 {
+		return 0, errors.New("no previous workspace recorded")
+	}
+	return 0, fmt.Errorf("fewer than %d other workspaces recorded", n)
+}
+
+// mruOrder returns the 0-based indices of count workspaces, those in hist
+// first by recency, then the never visited ones in index order.
+func mruOrder(hist []int, count int) []int {
+	seen := make(map[int]bool)
+	order := make([]int, 0, count)
+	for _, n := range hist {
+		if n >= 1 && n <= count && !seen[n-1] {
+			seen[n-1] = true
+			order = append(order, n-1)
+		}
+	}
+	for i := 0; i < count; i++ {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
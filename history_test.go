@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateDir(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"HOME": "/home/u"}, "/home/u/.local/state/gnav"},
+		{map[string]string{"HOME": "/home/u", "XDG_STATE_HOME": "/srv/state"}, "/srv/state/gnav"},
+	}
+	for _, tt := range tests {
+		getenv, _ := fakeEnv(tt.env)
+		if got := stateDir(getenv); got != tt.want {
+			t.Errorf("stateDir(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestRemapHistory(t *testing.T) {
+	old := historyFile
+	t.Cleanup(func() { historyFile = old })
+	historyFile = filepath.Join(t.TempDir(), "history")
+
+	// Deleting workspace 2 sends its windows to 1 and moves 3 and 4 down.
+	if err := saveHistory([]int{4, 2, 1, 3}); err != nil {
+		t.Fatal(err)
+	}
+	del := 1
+	if err := remapHistory(func(d int) int {
+		switch {
+		case d == del:
+			return -1
+		case d > del:
+			return d - 1
+		}
+		return d
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loadHistory(), []int{3, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after delete: history = %v, want %v", got, want)
+	}
+
+	// Swapping 1 and 3.
+	if err := remapHistory(func(d int) int {
+		switch d {
+		case 0:
+			return 2
+		case 2:
+			return 0
+		}
+		return d
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loadHistory(), []int{1, 3, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after swap: history = %v, want %v", got, want)
+	}
+
+	// Entries mapped onto each other keep the more recent position.
+	if err := remapHistory(func(int) int { return 0 }); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loadHistory(), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after merge: history = %v, want %v", got, want)
+	}
+}
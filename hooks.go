@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Hook directories
+// -----------------------------------------------------------------------------
+
+const defaultHookTimeout = 5 * time.Second
+
+// hookDir is the event's hook directory next to the config file. Profiles
+// share the main config's hooks.
+func hookDir(event string) string {
+	dir := filepath.Dir(configFile)
+	if profile != "" {
+		dir = configDir(os.Getenv)
+	}
+	return filepath.Join(dir, "hooks", event+".d")
+}
+
+func hookTimeout() time.Duration {
+	if d, err := time.ParseDuration(cfg.HookTimeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultHookTimeout
+}
+
+// Hooks are shell commands from the config run on workspace events, next
+// to the scripts in the event's hook directory. OnEnter and OnLeave are
+// keyed by workspace name or displayed index.
+type Hooks struct {
+	OnSwitch string            `yaml:"on_switch,omitempty"`
+	OnEnter  map[string]string `yaml:"on_enter,omitempty"`
+	OnLeave  map[string]string `yaml:"on_leave,omitempty"`
+	OnRename string            `yaml:"on_rename,omitempty"`
+	OnCreate string            `yaml:"on_create,omitempty"`
+}
+
+// startHook starts name in a session of its own with env added to its
+// environment, so a slow hook neither holds up the command that fired it
+// nor dies with it. The hook outlives gnav, so the timeout is left to
+// coreutils' timeout when it is installed. Long-running processes such as
+// the daemon reap it once it exits.
+func startHook(name string, args []string, env []string) {
+	if t, err := exec.LookPath("timeout"); err == nil {
+		secs := strconv.FormatFloat(hookTimeout().Seconds(), 'f', -1, 64)
+		args = append([]string{"-k", "1", secs, name}, args...)
+		name = t
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	go func() { _ = cmd.Wait() }()
+}
+
+// runHooks starts command, when set, through sh and every executable file
+// in the event's hook directory, passing args on the command line ($1, $2,
+// ... for command) and env in the environment.
+func runHooks(event, command string, args []string, env []string) {
+	env = append([]string{"GNAV_EVENT=" + strings.TrimPrefix(event, "on-")}, env...)
+	if command != "" {
+		startHook("sh", append([]string{"-c", command, "gnav-" + event}, args...), env)
+	}
+	entries, err := os.ReadDir(hookDir(event))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		path := filepath.Join(hookDir(event), e.Name())
+		st, err := os.Stat(path)
+		if err != nil || !st.Mode().IsRegular() || st.Mode()&0111 == 0 {
+			continue
+		}
+		startHook(path, args, env)
+	}
+}
+
+// workspaceHook returns the command hooks maps to the 0-based workspace
+// i, looked up by name (case-insensitively) or displayed index.
+func workspaceHook(hooks map[string]string, i int) string {
+	name := nameForIndex(i)
+	for key, command := range hooks {
+		if strings.EqualFold(strings.TrimSpace(key), name) || key == formatIndex(i) {
+			return command
+		}
+	}
+	return ""
+}
+
+// runSwitchHooks runs the hooks for a switch from the 1-based workspace
+// prev (0 when unknown) to idx: on-switch on every switch, and on-leave
+// and on-enter when the workspace changed. Each gets the new workspace in
+// GNAV_INDEX/GNAV_NAME and the previous one in GNAV_PREV_INDEX/
+// GNAV_PREV_NAME; on-leave is passed the workspace left as arguments, the
+// others the one entered.
+func runSwitchHooks(prev, idx int) {
+	name := nameForIndex(idx - 1)
+	env := []string{
+		"GNAV_INDEX=" + strconv.Itoa(idx),
+		"GNAV_NAME=" + name,
+	}
+	args := []string{strconv.Itoa(idx), name}
+	var prevArgs []string
+	if prev > 0 {
+		prevName := nameForIndex(prev - 1)
+		env = append(env, "GNAV_PREV_INDEX="+strconv.Itoa(prev), "GNAV_PREV_NAME="+prevName)
+		prevArgs = []string{strconv.Itoa(prev), prevName}
+	}
+	runHooks("on-switch", cfg.Hooks.OnSwitch, args, env)
+	if prev == idx {
+		return
+	}
+	if prev > 0 {
+		runHooks("on-leave", workspaceHook(cfg.Hooks.OnLeave, prev-1), prevArgs, env)
+	}
+	runHooks("on-enter", workspaceHook(cfg.Hooks.OnEnter, idx-1), args, env)
+}
+
+// runRenameHooks runs the on-rename hooks for the 1-based workspace idx,
+// passing its index, new name and old name.
+func runRenameHooks(idx int, oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+	runHooks("on-rename", cfg.Hooks.OnRename, []string{strconv.Itoa(idx), newName, oldName}, []string{
+		"GNAV_INDEX=" + strconv.Itoa(idx),
+		"GNAV_NAME=" + newName,
+		"GNAV_OLD_NAME=" + oldName,
+	})
+}
+
+// runCreateHooks runs the on-create hooks once for each of the 1-based
+// workspaces from and to, inclusive, with the new count in GNAV_COUNT.
+func runCreateHooks(from, to, count int) {
+	for idx := from; idx <= to; idx++ {
+		name := nameForIndex(idx - 1)
+		runHooks("on-create", cfg.Hooks.OnCreate, []string{strconv.Itoa(idx), name}, []string{
+			"GNAV_INDEX=" + strconv.Itoa(idx),
+			"GNAV_NAME=" + name,
+			"GNAV_COUNT=" + strconv.Itoa(count),
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Per-workspace input sources
+// -----------------------------------------------------------------------------
+
+// A workspace's input_source pins a GNOME input source, such as "us", "de",
+// "xkb:us+dvorak" or "ibus:anthy"; a bare name is an xkb layout. GNOME
+// Shell only picks a source up from the settings when its source list
+// changes, activating the first of mru-sources, so activating one means
+// moving it to the front of mru-sources and writing the list back.
+
+const inputSourcesSchema = "org.gnome.desktop.input-sources"
+
+// inputSource is a (type, id) pair from GNOME's sources list.
+type inputSource struct {
+	Type, ID string
+}
+
+func (s inputSource) String() string {
+	return fmt.Sprintf("('%s', '%s')", s.Type, s.ID)
+}
+
+// parseInputSourceName reads an input_source value.
+func parseInputSourceName(name string) inputSource {
+	if t, id, ok := strings.Cut(name, ":"); ok {
+		return inputSource{t, id}
+	}
+	return inputSource{"xkb", name}
+}
+
+var inputSourceTuple = regexp.MustCompile(`\(\s*'([^']*)'\s*,\s*'([^']*)'\s*\)`)
+
+// parseInputSources parses a GVariant a(ss) as printed by gsettings, e.g.
+// [('xkb', 'us'), ('ibus', 'anthy')] or @a(ss) [].
+func parseInputSources(s string) []inputSource {
+	var out []inputSource
+	for _, m := range inputSourceTuple.FindAllStringSubmatch(s, -1) {
+		out = append(out, inputSource{m[1], m[2]})
+	}
+	return out
+}
+
+func formatInputSources(sources []inputSource) string {
+	parts := make([]string, len(sources))
+	for i, s := range sources {
+		parts[i] = s.String()
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func getInputSources(key string) ([]inputSource, error) {
+	out, err := exec.Command("gsettings", "get", inputSourcesSchema, key).Output()
+	if err != nil {
+		return nil, fmt.Errorf("gsettings: %v", err)
+	}
+	return parseInputSources(string(out)), nil
+}
+
+func setInputSources(key string, sources []inputSource) error {
+	out, err := exec.Command("gsettings", "set", inputSourcesSchema, key, formatInputSources(sources)).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("gsettings: %v: %s", err, msg)
+		}
+		return fmt.Errorf("gsettings: %v", err)
+	}
+	return nil
+}
+
+// activateInputSource makes the named input source the active one, unless
+// it already is. It must be one of GNOME's configured sources.
+func activateInputSource(name string) error {
+	want := parseInputSourceName(name)
+	sources, err := getInputSources("sources")
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, s := range sources {
+		if s == want {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("input source %q isn't in GNOME's input sources", name)
+	}
+	mru, err := getInputSources("mru-sources")
+	if err != nil {
+		return err
+	}
+	if len(mru) > 0 && mru[0] == want {
+		return nil
+	}
+	front := []inputSource{want}
+	for _, s := range mru {
+		if s != want {
+			front = append(front, s)
+		}
+	}
+	if err := setInputSources("mru-sources", front); err != nil {
+		return err
+	}
+	return setInputSources("sources", sources)
+}
+
+// applyInputSource activates the input source pinned to the 1-based
+// workspace idx, if it has one and the session is GNOME.
+func applyInputSource(idx int) error {
+	name := strings.TrimSpace(workspaceMeta(idx - 1).InputSource)
+	if name == "" || !isGNOME(os.Getenv) {
+		return nil
+	}
+	return activateInputSource(name)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// -----------------------------------------------------------------------------
+// KRunner plugin
+// -----------------------------------------------------------------------------
+
+// The daemon also serves org.kde.krunner1, so KRunner queries starting with
+// krunnerKeyword ("ws dev") list matching workspaces. KRunner finds it
+// through a D-Bus plugin .desktop file (see README). Match IDs are 1-based
+// workspace indices.
+const (
+	krunnerPath    = dbus.ObjectPath("/org/gnav/KRunner")
+	krunnerIface   = "org.kde.krunner1"
+	krunnerKeyword = "ws"
+)
+
+// KRunner match types, from Plasma::QueryMatch::Type.
+const (
+	krunnerPossibleMatch = 30
+	krunnerExactMatch    = 100
+)
+
+const krunnerIntrospection = `
+<node>
+	<interface name="` + krunnerIface + `">
+		<method name="Actions">
+			<arg direction="out" type="a(sss)" name="actions"/>
+		</method>
+		<method name="Match">
+			<arg direction="in" type="s" name="query"/>
+			<arg direction="out" type="a(sssida{sv})" name="matches"/>
+		</method>
+		<method name="Run">
+			<arg direction="in" type="s" name="matchId"/>
+			<arg direction="in" type="s" name="actionId"/>
+		</method>
+	</interface>` + introspect.IntrospectDataString + `</node>`
+
+// krunnerMatch is one Match result, marshalled as (sssida{sv}).
+type krunnerMatch struct {
+	ID         string
+	Text       string
+	Icon       string
+	Type       int32
+	Relevance  float64
+	Properties map[string]dbus.Variant
+}
+
+// krunnerAction is one Actions entry, marshalled as (sss).
+type krunnerAction struct {
+	ID   string
+	Text string
+	Icon string
+}
+
+type krunnerRunner struct{ d *daemon }
+
+// exportKRunner serves krunnerIface on conn.
+func exportKRunner(conn *dbus.Conn, d *daemon) error {
+	if err := conn.Export(krunnerRunner{d}, krunnerPath, krunnerIface); err != nil {
+		return err
+	}
+	return conn.Export(introspect.Introspectable(krunnerIntrospection), krunnerPath,
+		"org.freedesktop.DBus.Introspectable")
+}
+
+func (r krunnerRunner) Actions() ([]krunnerAction, *dbus.Error) {
+	return []krunnerAction{}, nil
+}
+
+func (r krunnerRunner) Match(query string) ([]krunnerMatch, *dbus.Error) {
+	matches := []krunnerMatch{}
+	kw, q, _ := strings.Cut(strings.TrimSpace(query), " ")
+	q = strings.TrimSpace(q)
+	if !strings.EqualFold(kw, krunnerKeyword) || q == "" {
+		return matches, nil
+	}
+	reply, err := r.d.handle(daemonRequest{Cmd: "list"})
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	entries := reply.([]listEntry)
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	for rank, i := range rankNames(names, q) {
+		e := entries[i]
+		typ := int32(krunnerPossibleMatch)
+		if strings.EqualFold(e.Name, q) {
+			typ = krunnerExactMatch
+		}
+		matches = append(matches, krunnerMatch{
+			ID:        strconv.Itoa(e.Index),
+			Text:      e.Name,
+			Icon:      "preferences-desktop-virtual",
+			Type:      typ,
+			Relevance: 1 - float64(rank)/float64(len(entries)+1),
+			Properties: map[string]dbus.Variant{
+				"subtext": dbus.MakeVariant("Switch to workspace " + e.Label()),
+			},
+		})
+	}
+	return matches, nil
+}
+
+func (r krunnerRunner) Run(id, _ string) *dbus.Error {
+	if _, err := r.d.handle(daemonRequest{Cmd: "switch", Args: []string{id}}); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
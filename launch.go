@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Launching apps
+// -----------------------------------------------------------------------------
+
+// launchTimeout is how long launchOn waits for a started app's window.
+const launchTimeout = 10 * time.Second
+
+// startDetached runs command through sh in a session of its own, so the app
+// outlives gnav.
+func startDetached(command string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// shellJoin quotes args into one sh command line.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a != "" && strings.IndexFunc(a, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+		}) < 0 {
+			quoted[i] = a
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// launchOn starts command and moves the first window that appears after it
+// to the 1-based workspace idx, recording it as placed. Windows can't be
+// reliably tied to the process that opened them, so launches meant for
+// different workspaces must not overlap.
+func launchOn(command string, idx int, timeout time.Duration) (windowInfo, error) {
+	before, err := listWindows()
+	if err != nil {
+		return windowInfo{}, err
+	}
+	seen := make(map[string]bool, len(before))
+	for _, w := range before {
+		seen[w.ID] = true
+	}
+	if err := startDetached(command); err != nil {
+		return windowInfo{}, err
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		wins, err := listWindows()
+		if err != nil {
+			continue
+		}
+		for _, w := range wins {
+			if seen[w.ID] {
+				continue
+			}
+			if w.Desktop >= 0 && w.Desktop != idx-1 {
+				if err := moveWindow(w.ID, idx); err != nil {
+					return w, err
+				}
+				w.Desktop = idx - 1
+			}
+			recordPlaced(w.ID)
+			return w, nil
+		}
+	}
+	return windowInfo{}, fmt.Errorf("%q opened no window within %s", command, timeout)
+}
+
+// placedFile lists the windows launchOn put on a workspace, newest last,
+// so the rules daemon leaves them where they were asked to go.
+var placedFile = filepath.Join(filepath.Dir(historyFile), "placed")
+
+// maxPlaced is how many placed windows are remembered.
+const maxPlaced = 50
+
+func recordPlaced(id string) {
+	var ids []string
+	for _, p := range placedWindows() {
+		if p != id {
+			ids = append(ids, p)
+		}
+	}
+	ids = append(ids, id)
+	if len(ids) > maxPlaced {
+		ids = ids[len(ids)-maxPlaced:]
+	}
+	if err := os.MkdirAll(filepath.Dir(placedFile), 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(placedFile, []byte(strings.Join(ids, "\n")+"\n"), 0644)
+}
+
+func placedWindows() []string {
+	b, err := os.ReadFile(placedFile)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(b))
+}
+
+// -----------------------------------------------------------------------------
+// Autostart
+// -----------------------------------------------------------------------------
+
+// launchAutostart starts the autostart commands of the 1-based workspace
+// idx one after another, placing each window there. report is told about
+// every command as it opens or fails to; failures don't stop the rest.
+func launchAutostart(idx int, report func(cmd string, err error)) error {
+	cmds := workspaceMeta(idx - 1).Autostart
+	if len(cmds) == 0 {
+		return fmt.Errorf("%s has no autostart commands", nameForIndex(idx-1))
+	}
+	failed := 0
+	for _, cmd := range cmds {
+		_, err := launchOn(cmd, idx, launchTimeout)
+		if err != nil {
+			failed++
+		}
+		report(cmd, err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d autostart commands didn't start", failed, len(cmds))
+	}
+	return nil
+}
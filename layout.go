@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// -----------------------------------------------------------------------------
+// Export and import
+// -----------------------------------------------------------------------------
+
+// workspaceLayout is a workspace setup as written by `gnav export` and read
+// back by `gnav import`, to move it between machines or keep it in
+// dotfiles. Count 0 leaves the number of workspaces alone, as does a
+// missing Dynamic the mode.
+type workspaceLayout struct {
+	Count      int             `yaml:"count"`
+	Dynamic    *bool           `yaml:"dynamic,omitempty"`
+	Workspaces []WorkspaceMeta `yaml:"workspaces"`
+}
+
+func exportLayout() (*workspaceLayout, error) {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := getDynamic()
+	if err != nil {
+		return nil, err
+	}
+	// Backends that create workspaces on demand have no count to carry over.
+	if onDemandWorkspaces() {
+		sc = 0
+	}
+	return &workspaceLayout{
+		Count:      sc,
+		Dynamic:    &dyn,
+		Workspaces: append([]WorkspaceMeta{}, cfg.Workspaces...),
+	}, nil
+}
+
+// readLayout reads a layout from path, or from stdin for "-". The format
+// follows the extension as for config files; stdin takes YAML or JSON.
+func readLayout(path string) (*workspaceLayout, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var l workspaceLayout
+	if err := decodeConfig(path, b, &l); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if l.Count < 0 {
+		return nil, fmt.Errorf("%s: count must be >= 0", path)
+	}
+	return &l, nil
+}
+
+// importLayout sets the dynamic flag and, for static workspaces, the count
+// as set-count does, so workspaces that still hold windows aren't dropped.
+// Backends that create workspaces on demand skip the count.
+// Then it replaces the configured names and metadata with l's. The count
+// and mode are also saved, so `gnav restore` keeps enforcing them.
+func importLayout(l *workspaceLayout) error {
+	if l.Dynamic != nil {
+		if err := setDynamic(*l.Dynamic); err != nil {
+			return err
+		}
+	}
+	dyn, err := getDynamic()
+	if err != nil {
+		return err
+	}
+	if !dyn && l.Count > 0 && !onDemandWorkspaces() {
+		if err := setWorkspaceCount(l.Count, false); err != nil {
+			return fmt.Errorf("setting the workspace count: %v", err)
+		}
+	}
+	return updateConfig(func() error {
+		cfg.Workspaces = append([]WorkspaceMeta{}, l.Workspaces...)
+		cfg.Count = l.Count
+		if l.Dynamic != nil {
+			d := *l.Dynamic
+			cfg.Dynamic = &d
+		}
+		return nil
+	})
+}
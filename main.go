@@ -2,56 +2,357 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/gdamore/tcell/v2"
 	"github.com/gofrs/flock"
 	"github.com/rivo/tview"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
+	"golang.org/x/term"
 )
 
 // -----------------------------------------------------------------------------
 // Config struct + load/save
 // -----------------------------------------------------------------------------
+
+// WorkspaceMeta is the per-workspace part of the config. Color is a hex or
+// named color ("#89b4fa", "green") used for the workspace's menu and TUI
+// rows and as a waybar class; Icon is an emoji or glyph shown before its
+// name wherever gnav lists workspaces, or an image path that only wofi
+// menus show. Note is a free-form description shown under the name in the
+// TUI and in menus and tooltips. Auto marks a name given by auto-naming,
+// which may replace it again; renaming by hand clears it. Autostart lists
+// the commands `gnav launch` starts on the workspace. InputSource pins the
+// keyboard layout used on it; see inputsource.go. Focus silences
+// notification banners while the workspace is active; see dnd.go.
+type WorkspaceMeta struct {
+	Name  string `yaml:"name"`
+	Color string `yaml:"color,omitempty"`
+	Icon  string `yaml:"icon,omitempty"`
+	Note  string `yaml:"note,omitempty"`
+	Auto  bool   `yaml:"auto,omitempty"`
+
+	Autostart     []string `yaml:"autostart,omitempty"`
+	InputSource   string   `yaml:"input_source,omitempty"`
+	Focus         bool     `yaml:"focus,omitempty"`
+	LauncherStyle string   `yaml:"launcher_style,omitempty"`
+}
+
 type Config struct {
-	Names []string `yaml:"workspace_names"`
+	Workspaces []WorkspaceMeta `yaml:"workspaces,omitempty"`
+
+	// Names is the original flat list of workspace names. It is still read
+	// so old configs keep working, and the file is rewritten with them
+	// migrated into Workspaces as soon as it is loaded.
+	Names []string `yaml:"workspace_names,omitempty"`
+
+	// Count and Dynamic are the workspace count and dynamic mode that
+	// `gnav restore` enforces. Without Count restore only adds workspaces
+	// until every named one exists; without Dynamic it leaves the mode alone.
+	Count   int   `yaml:"count,omitempty"`
+	Dynamic *bool `yaml:"dynamic,omitempty"`
+
+	// IndexBase is the number shown for the first workspace (0 or 1). It is
+	// used both when displaying indices and when parsing them back.
+	IndexBase *int `yaml:"index_base,omitempty"`
+
+	// DefaultNameTemplate names workspaces without a configured name;
+	// {n} is replaced by the workspace position. Defaults to "Workspace {n}".
+	DefaultNameTemplate string `yaml:"default_name_template,omitempty"`
+
+	// NewWorkspaceLabel names the trailing slot GNOME keeps empty while
+	// dynamic workspaces are on. Defaults to "New Workspace".
+	NewWorkspaceLabel string `yaml:"new_workspace_label,omitempty"`
+
+	// IndexStyle selects how indices are displayed: "arabic" (default),
+	// "roman" or "letters".
+	IndexStyle string `yaml:"index_style,omitempty"`
+
+	// ClassNameMap maps a WM_CLASS (instance or class part, matched
+	// case-insensitively) to the name auto-name gives its workspace.
+	ClassNameMap map[string]string `yaml:"class_name_map,omitempty"`
+
+	// LauncherStyle is passed to wofi as --style, unless the workspace
+	// that is active when the menu opens has a launcher_style of its own.
+	// WorkspaceLauncherStyles is the old per-workspace map keyed by name;
+	// like Names it is migrated into Workspaces when loaded.
+	LauncherStyle           string            `yaml:"launcher_style,omitempty"`
+	WorkspaceLauncherStyles map[string]string `yaml:"workspace_launcher_styles,omitempty"`
+
+	// MenuCommand replaces the launcher run by wofi-run and by menu without
+	// --launcher, e.g. "fuzzel --dmenu --prompt {prompt}". {prompt} and
+	// {style} (the launcher style above) are substituted after splitting
+	// into words. MenuMarkup says whether the command renders Pango markup.
+	MenuCommand string `yaml:"menu_command,omitempty"`
+	MenuMarkup  bool   `yaml:"menu_markup,omitempty"`
+
+	// NotifyOnSwitch shows a desktop notification with the workspace's
+	// name on every switch; see notify.go. NotifyFormat replaces the name
+	// with a Go template over the workspace, as list's --format takes.
+	NotifyOnSwitch bool   `yaml:"notify_on_switch,omitempty"`
+	NotifyFormat   string `yaml:"notify_format,omitempty"`
+
+	// MenuOrder lists workspaces in menus by "index" (the default),
+	// "frecency" or "mru"; see menu.go.
+	MenuOrder string `yaml:"menu_order,omitempty"`
+
+	// MenuArgs are appended to that same launcher's command line, e.g.
+	// ["--style", "~/.config/wofi/ws.css"].
+	MenuArgs []string `yaml:"menu_args,omitempty"`
+
+	// MenuActiveMarkup wraps the active row of markup menus, with {line}
+	// and {color} (the workspace's color, else theme.wofi_active) filled
+	// in. MenuActivePrefix and MenuActiveSuffix are put around the active
+	// row's text; plain menus get a "* " prefix unless one is set.
+	MenuActiveMarkup string `yaml:"menu_active_markup,omitempty"`
+	MenuActivePrefix string `yaml:"menu_active_prefix,omitempty"`
+	MenuActiveSuffix string `yaml:"menu_active_suffix,omitempty"`
+
+	// MenuPlain turns Pango markup off in every menu, as does setting
+	// NO_COLOR in the environment.
+	MenuPlain bool `yaml:"menu_plain,omitempty"`
+
+	// HideMenuActions leaves the new, rename and close-empty rows out of
+	// interactive menus.
+	HideMenuActions bool `yaml:"hide_menu_actions,omitempty"`
+
+	// Backend forces a workspace backend by name instead of detecting one
+	// from the session; "auto" or empty means detect.
+	Backend string `yaml:"backend,omitempty"`
+
+	// Hooks are commands run on switches, renames and new workspaces; see
+	// hooks.go. HookTimeout bounds how long each hook may run, as a Go
+	// duration such as "5s".
+	Hooks       Hooks  `yaml:"hooks,omitempty"`
+	HookTimeout string `yaml:"hook_timeout,omitempty"`
+
+	// TUIPreview starts the TUI with the windows pane shown.
+	TUIPreview bool `yaml:"tui_preview,omitempty"`
+
+	// NameSync mirrors names with GNOME's workspace-names setting: "push"
+	// writes gnav's names on every save, "pull" adopts GNOME's at startup,
+	// "merge" does both, and "off" (default) leaves GNOME alone.
+	// NameSyncPrefer picks the winner when a merge finds two different
+	// names for one workspace: "gnav" (default) or "gnome".
+	NameSync       string `yaml:"name_sync,omitempty"`
+	NameSyncPrefer string `yaml:"name_sync_prefer,omitempty"`
+
+	// Rules send windows to workspaces by class and title: on demand with
+	// `gnav apply-rules`, and as they open while the daemon runs with
+	// RulesDaemon set. See rules.go.
+	Rules       []WindowRule `yaml:"rules,omitempty"`
+	RulesDaemon bool         `yaml:"rules_daemon,omitempty"`
+
+	// AutoName configures naming workspaces after their apps; see
+	// autoname.go.
+	AutoName AutoName `yaml:"auto_name,omitempty"`
+
+	// Theme colors the TUI and the menus' active row; see theme.go.
+	Theme Theme `yaml:"theme,omitempty"`
 }
 
 var (
-	configFile = filepath.Join(os.Getenv("HOME"), ".config", "gnav", "workspaces.yaml")
+	configFile = defaultConfigFile(os.Getenv)
 	cfg        = &Config{}
+
+	// cfgModTime is the config file's mtime as of the last load or save.
+	cfgModTime time.Time
+
+	// configErr is why the config file couldn't be loaded the last time it
+	// was read, if it couldn't; commands then run on the defaults after a
+	// warning, and nothing is saved over the file until it loads again.
+	configErr error
 )
 
+// configDir is the gnav directory under $XDG_CONFIG_HOME, or ~/.config
+// without it.
+func configDir(getenv func(string) string) string {
+	dir := getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "gnav")
+}
+
+// defaultConfigFile returns $GNAV_CONFIG when set, else workspaces.yaml in
+// configDir, or the .toml or .json file there if that is the one that
+// exists. The global --config and --profile flags override both.
+func defaultConfigFile(getenv func(string) string) string {
+	if p := getenv("GNAV_CONFIG"); p != "" {
+		return p
+	}
+	return findConfigFile(filepath.Join(configDir(getenv), "workspaces.yaml"))
+}
+
 func loadConfig() error {
+	needsSave, err := readConfig()
+	configErr = err
+	if err != nil || !needsSave {
+		return err
+	}
+	return saveConfig()
+}
+
+// readConfig loads the config file into cfg. needsSave is set when the file
+// should be written back: it didn't exist yet and cfg holds the defaults,
+// or it was migrated from the old shape and is rewritten once so that
+// happens on the first load rather than whenever something is next saved.
+func readConfig() (needsSave bool, err error) {
 	b, err := ioutil.ReadFile(configFile)
 	if os.IsNotExist(err) {
-		cfg.Names = []string{"Workspace 1", "Workspace 2"}
-		return saveConfig()
+		cfg.Workspaces = []WorkspaceMeta{{Name: "Workspace 1"}, {Name: "Workspace 2"}}
+		return true, nil
 	}
 	if err != nil {
-		return err
+		return false, err
+	}
+	if st, err := os.Stat(configFile); err == nil {
+		cfgModTime = st.ModTime()
+	}
+	var fresh Config
+	if err := decodeConfig(configFile, b, &fresh); err != nil {
+		if problems := checkConfigData(configFile, b); len(problems) > 0 {
+			return false, fmt.Errorf("%s (run 'gnav config check' for details)", problems[0])
+		}
+		return false, err
 	}
-	return yaml.Unmarshal(b, cfg)
+	*cfg = fresh
+	return migrateConfig(), nil
 }
 
-func saveConfig() error {
+// migrateConfig moves an old-style workspace_names list and
+// workspace_launcher_styles map into Workspaces and reports whether there
+// was either. Styles keyed by a name no workspace has are dropped.
+func migrateConfig() bool {
+	if len(cfg.Names) == 0 && len(cfg.WorkspaceLauncherStyles) == 0 {
+		return false
+	}
+	if len(cfg.Workspaces) == 0 {
+		for _, n := range cfg.Names {
+			cfg.Workspaces = append(cfg.Workspaces, WorkspaceMeta{Name: n})
+		}
+	}
+	cfg.Names = nil
+	for i := range cfg.Workspaces {
+		w := &cfg.Workspaces[i]
+		if style, ok := cfg.WorkspaceLauncherStyles[w.Name]; ok && w.LauncherStyle == "" {
+			w.LauncherStyle = style
+		}
+	}
+	cfg.WorkspaceLauncherStyles = nil
+	return true
+}
+
+// lockConfig takes the advisory lock that serialises config writes between
+// gnav processes, e.g. the TUI and a key-bound `gnav rename`.
+func lockConfig() (*flock.Flock, error) {
 	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return nil, err
+	}
+	lock := flock.New(configFile + ".lock")
+	return lock, lock.Lock()
+}
+
+func saveConfig() error {
+	if err := configWritable(); err != nil {
+		return err
+	}
+	lock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return writeConfig()
+}
+
+// errUnchanged is returned by an updateConfig change that found nothing to
+// change; updateConfig then skips the write and returns nil.
+var errUnchanged = errors.New("config unchanged")
+
+// updateConfig runs change and saves the result under the config lock. If
+// another process wrote the file since it was loaded, it is read again
+// first so that edit isn't lost.
+func updateConfig(change func() error) error {
+	lock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if configChangedOnDisk() {
+		_, configErr = readConfig()
+	}
+	if err := configWritable(); err != nil {
+		return err
+	}
+	if err := change(); err == errUnchanged {
+		return nil
+	} else if err != nil {
 		return err
 	}
-	data, err := yaml.Marshal(cfg)
+	return writeConfig()
+}
+
+// configWritable refuses to save while the config file doesn't load, since
+// cfg then holds the defaults or an older version rather than what is in
+// the file, and writing it would throw the user's edits away.
+func configWritable() error {
+	if configErr != nil {
+		return fmt.Errorf("not saving over %s while it doesn't load: %v", configFile, configErr)
+	}
+	return nil
+}
+
+// writeConfig writes cfg to a temporary file and renames it over the config
+// file, so readers never see a partly written file. A symlinked config,
+// e.g. one kept in a dotfiles repo, is written through to its target so
+// the link survives. The caller holds the config lock.
+func writeConfig() error {
+	data, err := encodeConfig(configFile, cfg)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(configFile, data, 0644)
+	invalidateMenuCache()
+	target := configFile
+	if real, err := filepath.EvalSymlinks(configFile); err == nil {
+		target = real
+	}
+	tmp := target + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if st, err := os.Stat(configFile); err == nil {
+		cfgModTime = st.ModTime()
+	}
+	if m := nameSyncMode(); m == nameSyncPush || m == nameSyncMerge {
+		_ = pushNames()
+	}
+	return nil
+}
+
+// configChangedOnDisk reports whether another process wrote the config file
+// since this one last loaded or saved it.
+func configChangedOnDisk() bool {
+	st, err := os.Stat(configFile)
+	if err != nil {
+		return false
+	}
+	return !st.ModTime().Equal(cfgModTime)
 }
 
 // -----------------------------------------------------------------------------
@@ -59,570 +360,2999 @@ func saveConfig() error {
 // -----------------------------------------------------------------------------
 
 func getSystemWorkspaceCount() (int, error) {
-	out, err := exec.Command("wmctrl", "-d").Output()
-	if err != nil {
-		return 0, err
-	}
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	return len(lines), nil
+	return backend.Count()
 }
 
 func getActiveWorkspaceIndex() (int, error) {
-	out, err := exec.Command("wmctrl", "-d").Output()
+	return backend.ActiveIndex()
+}
+
+type windowInfo struct {
+	ID      string
+	Desktop int
+	Class   string // WM_CLASS as "instance.Class"
+	Title   string
+}
+
+// listWindows lists all windows, through the backend when it can and
+// wmctrl otherwise. Sticky windows report desktop -1.
+func listWindows() ([]windowInfo, error) {
+	if wl, ok := backend.(windowLister); ok {
+		return wl.Windows()
+	}
+	return wmctrlBackend{}.Windows()
+}
+
+// getActiveWindowID returns the X11 id of the focused window.
+func getActiveWindowID() (uint64, error) {
+	out, err := exec.Command("xprop", "-root", "_NET_ACTIVE_WINDOW").Output()
 	if err != nil {
-		return -1, err
+		return 0, err
 	}
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	for i, line := range lines {
-		if strings.Contains(line, "*") {
-			return i, nil
-		}
+	f := strings.Fields(string(out))
+	if len(f) == 0 {
+		return 0, errors.New("no active window")
 	}
-	return -1, errors.New("no active workspace found")
+	return strconv.ParseUint(f[len(f)-1], 0, 64)
 }
 
 func getDynamic() (bool, error) {
-	out, err := exec.Command("gsettings", "get",
-		"org.gnome.mutter", "dynamic-workspaces").Output()
-	if err != nil {
-		return false, err
-	}
-	return strings.TrimSpace(string(out)) == "true", nil
+	return backend.Dynamic()
 }
 
 func setDynamic(on bool) error {
-	val := "false"
+	invalidateMenuCache()
 	if on {
-		val = "true"
+		forgetDynamic()
 	}
-	return exec.Command("gsettings", "set",
-		"org.gnome.mutter", "dynamic-workspaces", val).Run()
+	return backend.SetDynamic(on)
 }
 
-func switchWorkspace(idx int) error {
-	if idx < 1 {
-		return errors.New("invalid workspace index")
+// indexBase returns the configured display base, defaulting to 1.
+func indexBase() int {
+	if cfg.IndexBase != nil && *cfg.IndexBase == 0 {
+		return 0
 	}
-	cmd := exec.Command("wmctrl", "-s", strconv.Itoa(idx-1))
-	return cmd.Run()
+	return 1
 }
 
-func renameLocal(index int, newName string) error {
-	if index < 1 {
-		return fmt.Errorf("invalid index: %d", index)
-	}
-	for len(cfg.Names) < index {
-		cfg.Names = append(cfg.Names, fmt.Sprintf("Workspace %d", len(cfg.Names)+1))
+// fromDisplayIndex converts an index shown with the given base into the
+// 1-based index used by switchWorkspace and renameLocal.
+func fromDisplayIndex(n, base int) int {
+	return n - base + 1
+}
+
+// formatIndex renders the 0-based workspace i the way list, the menus and
+// the TUI show it, honouring index_base and index_style. Roman numerals and
+// letters have no zero, so those styles always count from I / a.
+func formatIndex(i int) string {
+	switch cfg.IndexStyle {
+	case "roman":
+		if r := toRoman(i + 1); r != "" {
+			return r
+		}
+	case "letters":
+		return toLetters(i + 1)
 	}
-	cfg.Names[index-1] = newName
-	return saveConfig()
+	return strconv.Itoa(i + indexBase())
 }
 
-func createWorkspaces(num int) error {
-	if num < 1 {
-		return errors.New("workspaces must be >= 1")
+// parseIndex is the inverse of formatIndex and returns a 1-based index.
+// Plain numbers are accepted whatever the style.
+func parseIndex(tok string) (int, error) {
+	switch cfg.IndexStyle {
+	case "roman":
+		if n, ok := fromRoman(tok); ok {
+			return n, nil
+		}
+	case "letters":
+		if n, ok := fromLetters(tok); ok {
+			return n, nil
+		}
 	}
-	sc, err := getSystemWorkspaceCount()
+	n, err := strconv.Atoi(tok)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if num > sc {
-		_ = exec.Command("gsettings", "set",
-			"org.gnome.desktop.wm.preferences", "num-workspaces",
-			strconv.Itoa(num)).Run()
-		_ = exec.Command("gsettings", "set",
-			"org.gnome.mutter", "dynamic-workspaces", "false").Run()
+	return fromDisplayIndex(n, indexBase()), nil
+}
+
+var romanNumerals = []struct {
+	value  int
+	symbol string
+}{
+	{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"},
+	{100, "C"}, {90, "XC"}, {50, "L"}, {40, "XL"},
+	{10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+}
+
+// toRoman returns n in roman numerals, or "" outside 1..3999.
+func toRoman(n int) string {
+	if n < 1 || n > 3999 {
+		return ""
 	}
-	for len(cfg.Names) < num {
-		cfg.Names = append(cfg.Names, fmt.Sprintf("Workspace %d", len(cfg.Names)+1))
+	var b strings.Builder
+	for _, r := range romanNumerals {
+		for n >= r.value {
+			b.WriteString(r.symbol)
+			n -= r.value
+		}
 	}
-	return saveConfig()
+	return b.String()
 }
 
-// -----------------------------------------------------------------------------
-// Wofi integration
-// -----------------------------------------------------------------------------
+func fromRoman(s string) (int, bool) {
+	s = strings.ToUpper(s)
+	n, rest := 0, s
+	for _, r := range romanNumerals {
+		for strings.HasPrefix(rest, r.symbol) {
+			n += r.value
+			rest = rest[len(r.symbol):]
+		}
+	}
+	// Round-trip to reject non-canonical forms such as "IIII", and "".
+	if rest != "" || n == 0 || toRoman(n) != s {
+		return 0, false
+	}
+	return n, true
+}
 
-func wofiIntegration() error {
-	if err := loadConfig(); err != nil {
-		return err
+// toLetters returns n in bijective base 26: a..z, aa, ab, ...
+func toLetters(n int) string {
+	var b []byte
+	for n > 0 {
+		n--
+		b = append([]byte{byte('a' + n%26)}, b...)
+		n /= 26
 	}
-	dyn, _ := getDynamic()
-	sc, err := getSystemWorkspaceCount()
-	if err != nil {
-		return err
+	return string(b)
+}
+
+func fromLetters(s string) (int, bool) {
+	if s == "" {
+		return 0, false
 	}
-	activeIdx, _ := getActiveWorkspaceIndex()
-	for i := 0; i < sc; i++ {
-		var name string
-		if i < len(cfg.Names) {
-			name = cfg.Names[i]
-		} else {
-			name = fmt.Sprintf("Workspace %d", i+1)
-		}
-		if dyn && i == sc-1 {
-			name = "New Workspace"
-		}
-		if i == activeIdx {
-			fmt.Printf("<span foreground='#ff5555'>%d: %s</span>\n", i+1, name)
-		} else {
-			fmt.Printf("%d: %s\n", i+1, name)
+	n := 0
+	for _, c := range strings.ToLower(s) {
+		if c < 'a' || c > 'z' {
+			return 0, false
 		}
+		n = n*26 + int(c-'a'+1)
 	}
-	return nil
+	return n, true
 }
 
-func parseWofiSelection() error {
-	scanner := bufio.NewScanner(os.Stdin)
-	if !scanner.Scan() {
-		return errors.New("no input")
+// dynamicTarget maps the 1-based idx one past the last workspace onto the
+// last one while dynamic workspaces are on: that is the empty workspace
+// GNOME keeps at the end, and using it makes GNOME add the next one. Other
+// indices are returned unchanged.
+func dynamicTarget(idx int) int {
+	dyn, _ := getDynamic()
+	if !dyn {
+		return idx
 	}
-	line := strings.TrimSpace(scanner.Text())
-	if line == "" {
-		return errors.New("empty input")
+	if sc, err := getSystemWorkspaceCount(); err == nil && idx == sc+1 {
+		return sc
+	}
+	return idx
+}
+
+func switchWorkspace(idx int) error {
+	if idx < 1 {
+		return errors.New("invalid workspace index")
 	}
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) < 2 {
-		return errors.New("invalid format: 'idx: name'")
+	idx = dynamicTarget(idx)
+	invalidateMenuCache()
+	// Record the workspace being left too, in case it was reached without
+	// gnav and so isn't in the history yet.
+	prev := 0
+	if cur, err := getActiveWorkspaceIndex(); err == nil {
+		prev = cur + 1
+		recordVisit(prev)
 	}
-	idx, err := strconv.Atoi(strings.TrimSpace(parts[0]))
-	if err != nil {
+	if err := backend.Switch(idx - 1); err != nil {
 		return err
 	}
-	return switchWorkspace(idx)
+	recordVisit(idx)
+	_ = applyInputSource(idx)
+	if cfg.NotifyOnSwitch && prev != idx && !daemonRunning() {
+		_ = (&switchNotifier{}).notify(idx)
+	}
+	runSwitchHooks(prev, idx)
+	return nil
 }
 
-func wofiRun() error {
-	if err := loadConfig(); err != nil {
-		return err
+// matchNames returns the 0-based indices of names that match query. With
+// exact only identical names match. Otherwise the names sharing the best
+// nameScore win.
+func matchNames(names []string, query string, exact bool) []int {
+	if exact {
+		for i, n := range names {
+			if n == query {
+				return []int{i}
+			}
+		}
+		return nil
 	}
-	dyn, _ := getDynamic()
-	sc, err := getSystemWorkspaceCount()
-	if err != nil {
-		return err
+	best := 0
+	var hits []int
+	for i, n := range names {
+		switch score := nameScore(n, query); {
+		case score == 0 || score < best:
+		case score > best:
+			best, hits = score, []int{i}
+		default:
+			hits = append(hits, i)
+		}
 	}
-	activeIdx, _ := getActiveWorkspaceIndex()
+	return hits
+}
 
-	var buf bytes.Buffer
-	for i := 0; i < sc; i++ {
-		var nm string
-		if i < len(cfg.Names) {
-			nm = cfg.Names[i]
-		} else {
-			nm = fmt.Sprintf("Workspace %d", i+1)
+// rankNames returns the 0-based indices of every name that matches query
+// at all, best matches first and in order within a tier, for search
+// results where weaker matches are still worth listing.
+func rankNames(names []string, query string) []int {
+	var hits []int
+	scores := make([]int, len(names))
+	for i, n := range names {
+		if scores[i] = nameScore(n, query); scores[i] > 0 {
+			hits = append(hits, i)
 		}
-		if dyn && i == sc-1 {
-			nm = "New Workspace"
+	}
+	sort.SliceStable(hits, func(a, b int) bool { return scores[hits[a]] > scores[hits[b]] })
+	return hits
+}
+
+// Match tiers scored by nameScore.
+const (
+	scoreSubsequence = 25
+	scoreContains    = 50
+	scorePrefix      = 75
+	scoreEqual       = 100
+)
+
+// nameScore rates how well name matches query, case-insensitively:
+// equality, prefix, substring, and finally the query's letters appearing
+// in order (so "dvl" finds "Development"). It is 0 when they don't match.
+func nameScore(name, query string) int {
+	n, q := strings.ToLower(name), strings.ToLower(query)
+	switch {
+	case n == q:
+		return scoreEqual
+	case strings.HasPrefix(n, q):
+		return scorePrefix
+	case strings.Contains(n, q):
+		return scoreContains
+	case isSubsequence(q, n):
+		return scoreSubsequence
+	}
+	return 0
+}
+
+func isSubsequence(sub, s string) bool {
+	rs := []rune(sub)
+	for _, r := range s {
+		if len(rs) == 0 {
+			break
 		}
-		if i == activeIdx {
-			buf.WriteString(fmt.Sprintf("<span foreground='#ff5555'>%d: %s</span>\n", i+1, nm))
-		} else {
-			buf.WriteString(fmt.Sprintf("%d: %s\n", i+1, nm))
+		if r == rs[0] {
+			rs = rs[1:]
 		}
 	}
-	cmd := exec.Command("wofi", "--show", "dmenu", "-i", "--allow-images", "--allow-markup")
-	cmd.Stdin = &buf
-	out, err2 := cmd.Output()
-	if err2 != nil {
-		return fmt.Errorf("wofi canceled or error: %v", err2)
+	return len(rs) == 0
+}
+
+// workspaceByName resolves a name query to a 1-based index, listing the
+// candidates when it is ambiguous.
+func workspaceByName(query string, exact bool) (int, error) {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return 0, err
 	}
-	sel := strings.TrimSpace(string(out))
-	if sel == "" {
-		return errors.New("no selection from wofi")
+	names := make([]string, sc)
+	for i := range names {
+		names[i] = nameForIndex(i)
 	}
-	parts := strings.SplitN(sel, ":", 2)
-	if len(parts) < 2 {
-		return errors.New("invalid selection format from wofi")
+	hits := matchNames(names, query, exact)
+	switch len(hits) {
+	case 0:
+		return 0, fmt.Errorf("no workspace matches %q", query)
+	case 1:
+		return hits[0] + 1, nil
 	}
-	idx, e := strconv.Atoi(strings.TrimSpace(parts[0]))
-	if e != nil {
-		return e
+	var cands []string
+	for _, i := range hits {
+		cands = append(cands, fmt.Sprintf("[%s] %s", formatIndex(i), names[i]))
 	}
-	return switchWorkspace(idx)
+	return 0, fmt.Errorf("%q is ambiguous: %s", query, strings.Join(cands, ", "))
 }
 
-// -----------------------------------------------------------------------------
-// TUI
-// -----------------------------------------------------------------------------
-
-func setTUIViewTheme() {
-	tview.Styles.PrimitiveBackgroundColor = tcell.GetColor("#1E1E2E")
-	tview.Styles.ContrastBackgroundColor = tcell.GetColor("#313244")
-	tview.Styles.MoreContrastBackgroundColor = tcell.GetColor("#45475A")
-	tview.Styles.BorderColor = tcell.GetColor("#F5E0DC")
-	tview.Styles.TitleColor = tcell.GetColor("#F5E0DC")
-	tview.Styles.GraphicsColor = tcell.GetColor("#F5E0DC")
-	tview.Styles.PrimaryTextColor = tcell.GetColor("#D9E0EE")
-	tview.Styles.SecondaryTextColor = tcell.GetColor("#D9E0EE")
-	tview.Styles.TertiaryTextColor = tcell.GetColor("#D9E0EE")
-	tview.Styles.InverseTextColor = tcell.GetColor("#1E1E2E")
-	tview.Styles.ContrastSecondaryTextColor = tcell.GetColor("#F5E0DC")
+// queryMatch is one result of `gnav query`. Action is the command line
+// that switches to the workspace.
+type queryMatch struct {
+	Name   string `json:"name"`
+	Index  int    `json:"index"`
+	Score  int    `json:"score"`
+	Action string `json:"action"`
 }
 
-type TUI struct {
-	app       *tview.Application
-	layout    *tview.Flex
-	list      *tview.List
-	renameBox *tview.InputField
-	foot      *tview.TextView
+// queryWorkspaces returns every workspace matching text, best first and by
+// index within a score. An empty text matches all workspaces.
+func queryWorkspaces(text string) ([]queryMatch, error) {
+	entries, err := fetchEntries()
+	if err != nil {
+		return nil, err
+	}
+	matches := []queryMatch{}
+	for _, e := range entries {
+		if score := nameScore(e.Name, text); score > 0 {
+			matches = append(matches, queryMatch{
+				Name:   e.Name,
+				Index:  e.Index,
+				Score:  score,
+				Action: fmt.Sprintf("gnav switch --index-base 1 %d", e.Index),
+			})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches, nil
 }
 
-func runTUI() error {
-	setTUIViewTheme()
-	sc, _ := getSystemWorkspaceCount()
-	activeIdx, _ := getActiveWorkspaceIndex()
+// nameForIndex returns the configured name of the 0-based workspace i, or the
+// "Workspace N" fallback when none is configured.
+func nameForIndex(i int) string {
+	if i >= 0 && i < len(cfg.Workspaces) {
+		return cfg.Workspaces[i].Name
+	}
+	return defaultName(i)
+}
 
-	app := tview.NewApplication()
+// workspaceMeta returns the config entry of the 0-based workspace i, or a
+// zero entry when none is configured.
+func workspaceMeta(i int) WorkspaceMeta {
+	if i >= 0 && i < len(cfg.Workspaces) {
+		return cfg.Workspaces[i]
+	}
+	return WorkspaceMeta{}
+}
 
-	head := tview.NewTextView()
-	head.SetText("GNAV TUI").SetTextAlign(tview.AlignCenter)
+// iconIsImage reports whether a workspace icon is an image file rather than
+// a glyph.
+func iconIsImage(icon string) bool {
+	return strings.HasPrefix(icon, "/") || strings.HasPrefix(icon, "~/")
+}
 
-	foot := tview.NewTextView()
-	foot.SetText("[↑/↓] Move  [Enter] Switch  [X] Remove  [?] More  [Q/Esc] Quit")
+// iconText returns icon for text output, or "" for image icons.
+func iconText(icon string) string {
+	if iconIsImage(icon) {
+		return ""
+	}
+	return icon
+}
 
-	list := tview.NewList()
-	list.SetBorder(true)
-	list.SetTitle(" Workspaces ")
-	list.ShowSecondaryText(false)
+// expandHome replaces a leading ~/ in path with the home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return path
+}
 
-	dyn, _ := getDynamic()
+// ensureWorkspaces pads the config with default-named entries so that at
+// least n workspaces have one.
+func ensureWorkspaces(n int) {
+	for len(cfg.Workspaces) < n {
+		cfg.Workspaces = append(cfg.Workspaces, WorkspaceMeta{Name: nameForIndex(len(cfg.Workspaces))})
+	}
+}
 
-	var items []string
-	maxLen := 0
-	for i := 0; i < sc; i++ {
-		var nm string
-		if i < len(cfg.Names) {
-			nm = cfg.Names[i]
-		} else {
-			nm = fmt.Sprintf("Workspace %d", i+1)
-		}
-		if dyn && i == sc-1 {
-			nm = "New Workspace"
-		}
-		entry := fmt.Sprintf("(%d) %s", i+1, nm)
-		if len(entry) > maxLen {
-			maxLen = len(entry)
-		}
-		items = append(items, entry)
+// defaultName is the fallback name of the 0-based workspace i, built from
+// default_name_template with {n} replaced by the 1-based position.
+func defaultName(i int) string {
+	tmpl := cfg.DefaultNameTemplate
+	if tmpl == "" {
+		tmpl = "Workspace {n}"
 	}
-	for i, entry := range items {
-		if i == activeIdx {
-			list.AddItem(fmt.Sprintf("%-*s  *", maxLen, entry), "", 0, nil)
-		} else {
-			list.AddItem(entry, "", 0, nil)
+	return strings.ReplaceAll(tmpl, "{n}", strconv.Itoa(i+1))
+}
+
+// displayName is nameForIndex with the trailing slot of count workspaces
+// shown as new_workspace_label while dynamic workspaces are on, since GNOME
+// keeps that one empty for creating new workspaces.
+func displayName(i, count int, dyn bool) string {
+	if dyn && i == count-1 {
+		if cfg.NewWorkspaceLabel != "" {
+			return cfg.NewWorkspaceLabel
 		}
+		return "New Workspace"
 	}
+	return nameForIndex(i)
+}
 
-	list.SetCurrentItem(activeIdx)
-
-	tui := &TUI{
-		app:    app,
-		layout: nil,
-		list:   list,
-		foot:   foot,
+// adjacentWorkspace returns the 1-based index delta steps away from the
+// active workspace. Without wrap, stepping past either end reports ok=false.
+// Workspaces for which skip (given 0-based indices, may be nil) returns
+// true are stepped over; ok is false if every workspace is skipped, or
+// every one but the active workspace, so the step would wrap back to it.
+func adjacentWorkspace(delta int, wrap bool, skip func(int) bool) (idx int, ok bool, err error) {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return 0, false, err
 	}
-
-	reload := func() {
-		_ = loadConfig()
-		s, _ := getSystemWorkspaceCount()
-		aIdx, _ := getActiveWorkspaceIndex()
-		dynRefresh, _ := getDynamic()
-
-		var newItems []string
-		newMax := 0
-		for i := 0; i < s; i++ {
-			var nm string
-			if i < len(cfg.Names) {
-				nm = cfg.Names[i]
-			} else {
-				nm = fmt.Sprintf("Workspace %d", i+1)
-			}
-			if dynRefresh && i == s-1 {
-				nm = "New Workspace"
+	cur, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return 0, false, err
+	}
+	step, n := 1, delta
+	if delta < 0 {
+		step, n = -1, -delta
+	}
+	t := cur
+	for i := 0; i < n; i++ {
+		for tries := 0; ; tries++ {
+			if tries == sc {
+				return cur + 1, false, nil
 			}
-			entry := fmt.Sprintf("(%d) %s", i+1, nm)
-			if len(entry) > newMax {
-				newMax = len(entry)
+			t += step
+			if wrap {
+				t = ((t % sc) + sc) % sc
+			} else if t < 0 || t >= sc {
+				return cur + 1, false, nil
 			}
-			newItems = append(newItems, entry)
-		}
-
-		list.Clear()
-		for i, entry := range newItems {
-			if i == aIdx {
-				list.AddItem(fmt.Sprintf("%-*s  *", newMax, entry), "", 0, nil)
-			} else {
-				list.AddItem(entry, "", 0, nil)
+			if skip == nil || !skip(t) {
+				break
 			}
 		}
-		list.SetCurrentItem(aIdx)
 	}
+	if skip != nil && t == cur {
+		return cur + 1, false, nil
+	}
+	return t + 1, true, nil
+}
 
-	startInlineRename := func(idx int) {
-		var cur string
-		if idx-1 < len(cfg.Names) {
-			cur = cfg.Names[idx-1]
-		} else {
-			cur = fmt.Sprintf("Workspace %d", idx)
+// Values of next and prev's --skip-empty: when no other workspace has
+// windows, skipEmptyStay stays put and skipEmptyNext steps as if
+// --skip-empty wasn't given. A bare --skip-empty means skipEmptyStay.
+const (
+	skipEmptyStay = "stay"
+	skipEmptyNext = "next"
+)
+
+// stepWorkspace switches delta workspaces away from the active one. With
+// dynamic workspaces on, the trailing "New Workspace" slot is part of the
+// cycle, as in the TUI. Without wrap, stepping off either end is a no-op.
+// With skipEmpty set, workspaces without windows are stepped over, and it
+// says what to do when that leaves nowhere to go. With screenRelative set,
+// so are workspaces on other outputs than the active one.
+func stepWorkspace(delta int, wrap bool, skipEmpty string, screenRelative bool) error {
+	var offScreen func(int) bool
+	if screenRelative {
+		var err error
+		if offScreen, err = offScreenWorkspaces(); err != nil {
+			return err
 		}
-		tui.renameBox = tview.NewInputField().SetText(cur)
-		tui.renameBox.SetDoneFunc(func(key tcell.Key) {
-			switch key {
-			case tcell.KeyEnter:
-				newN := tui.renameBox.GetText()
-				if newN != "" {
-					_ = renameLocal(idx, newN)
-					reload()
-				}
-				tui.layout.RemoveItem(tui.renameBox)
-				tui.layout.AddItem(tui.foot, 1, 1, false)
-				tui.app.SetFocus(tui.list)
-			case tcell.KeyEsc:
-				tui.layout.RemoveItem(tui.renameBox)
-				tui.layout.AddItem(tui.foot, 1, 1, false)
-				tui.app.SetFocus(tui.list)
-			}
-		})
-		tui.layout.RemoveItem(tui.foot)
-		tui.layout.AddItem(tui.renameBox, 1, 1, true)
-		tui.app.SetFocus(tui.renameBox)
 	}
-
-	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
-		sCount, _ := getSystemWorkspaceCount()
-		if index < sCount {
-			switchWorkspace(index + 1)
+	skip := offScreen
+	switch skipEmpty {
+	case "":
+	case skipEmptyStay, skipEmptyNext:
+		// Without a window list every workspace would look empty, and the
+		// step would silently go nowhere.
+		counts, err := windowCounts()
+		if err != nil {
+			return err
 		}
-	})
+		skip = func(i int) bool { return counts[i] == 0 || offScreen != nil && offScreen(i) }
+	default:
+		return fmt.Errorf("unknown --skip-empty %q (supported: %s, %s)", skipEmpty, skipEmptyStay, skipEmptyNext)
+	}
+	idx, ok, err := adjacentWorkspace(delta, wrap, skip)
+	if err == nil && !ok && skipEmpty == skipEmptyNext {
+		idx, ok, err = adjacentWorkspace(delta, wrap, offScreen)
+	}
+	if err != nil || !ok {
+		return err
+	}
+	return switchWorkspace(idx)
+}
 
-	list.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-		switch ev.Key() {
-		case tcell.KeyEsc:
-			app.Stop()
-			return nil
-		case tcell.KeyUp, tcell.KeyDown:
-			return ev
-		}
-		switch ev.Rune() {
-		case 'q', 'Q':
-			app.Stop()
-			return nil
-		case 'j':
-			n := (list.GetCurrentItem() + 1) % list.GetItemCount()
-			list.SetCurrentItem(n)
-			return nil
-		case 'k':
-			n := (list.GetCurrentItem() - 1 + list.GetItemCount()) % list.GetItemCount()
-			list.SetCurrentItem(n)
-			return nil
-		case 'r', 'R':
-			i := list.GetCurrentItem() + 1
-			startInlineRename(i)
-			return nil
-		case 'n', 'N':
-			createDialog(reload, tui)
-			return nil
-		case 'z', 'Z':
-			toggleDynamic(tui, reload)
-			return nil
-		case 'J':
-			i := list.GetCurrentItem()
-			if i < list.GetItemCount()-1 {
-				cfg.Names[i], cfg.Names[i+1] = cfg.Names[i+1], cfg.Names[i]
-				_ = saveConfig()
-				reload()
-				list.SetCurrentItem(i + 1)
-			}
-			return nil
-		case 'K':
-			i := list.GetCurrentItem()
-			if i > 0 {
-				cfg.Names[i], cfg.Names[i-1] = cfg.Names[i-1], cfg.Names[i]
-				_ = saveConfig()
-				reload()
-				list.SetCurrentItem(i - 1)
-			}
-			return nil
-		case 'x', 'X':
-			i := list.GetCurrentItem()
-			if i < len(cfg.Names) {
-				cfg.Names = append(cfg.Names[:i], cfg.Names[i+1:]...)
-				_ = saveConfig()
-				reload()
-				if i > list.GetItemCount()-1 {
-					i = list.GetItemCount() - 1
-				}
-				if i < 0 {
-					i = 0
-				}
-				list.SetCurrentItem(i)
+// offScreenWorkspaces returns a skip function for adjacentWorkspace that
+// reports the workspaces on other outputs than the active one. Workspaces
+// the backend doesn't list are on no output, so they are skipped too. EWMH
+// desktops span every monitor, so only backends that track outputs per
+// workspace support it.
+func offScreenWorkspaces() (func(int) bool, error) {
+	l, ok := backend.(outputLister)
+	if !ok {
+		return nil, errors.New("--screen-relative is not supported by this backend; it needs one that puts workspaces on outputs (i3, sway or Hyprland)")
+	}
+	outputs, err := l.WorkspaceOutputs()
+	if err != nil {
+		return nil, err
+	}
+	cur, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return nil, err
+	}
+	active := outputs[cur]
+	return func(i int) bool { return outputs[i] != active }, nil
+}
+
+// moveActiveWindow sends the focused window to the 1-based workspace idx.
+func moveActiveWindow(idx int) error {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return err
+	}
+	idx = dynamicTarget(idx)
+	if idx < 1 || idx > sc {
+		return fmt.Errorf("invalid workspace index: %d", idx)
+	}
+	if m, ok := backend.(windowMover); ok {
+		return m.MoveActiveWindow(idx - 1)
+	}
+	return wmctrlBackend{}.MoveActiveWindow(idx - 1)
+}
+
+// resolveWorkspace turns a workspace argument into a 1-based index: a
+// displayed index, "next"/"prev" relative to the active workspace, or a name
+// matched as by switch. ok is false when next/prev would step off either
+// end without wrap.
+func resolveWorkspace(arg string, wrap bool) (idx int, ok bool, err error) {
+	switch strings.ToLower(arg) {
+	case "next":
+		return adjacentWorkspace(1, wrap, nil)
+	case "prev":
+		return adjacentWorkspace(-1, wrap, nil)
+	}
+	if i, err := strconv.Atoi(arg); err == nil {
+		return fromDisplayIndex(i, indexBase()), true, nil
+	}
+	idx, err = workspaceByName(arg, false)
+	return idx, err == nil, err
+}
+
+// sendActiveWindow moves the focused window to the workspace named by arg
+// and, with follow, switches there too.
+func sendActiveWindow(arg string, wrap, follow bool) error {
+	target, ok, err := resolveWorkspace(arg, wrap)
+	if err != nil || !ok {
+		return err
+	}
+	// Resolve a new dynamic workspace once: moving the window there makes
+	// GNOME add another, which the switch would otherwise go to.
+	target = dynamicTarget(target)
+	if err := moveActiveWindow(target); err != nil {
+		return err
+	}
+	if follow {
+		return switchWorkspace(target)
+	}
+	return nil
+}
+
+// focusWindow raises and focuses the window with the given ID.
+func focusWindow(id string) error {
+	if c, ok := backend.(windowController); ok {
+		return c.FocusWindow(id)
+	}
+	return wmctrlBackend{}.FocusWindow(id)
+}
+
+// moveWindow sends the window with the given ID to the 1-based workspace
+// idx.
+func moveWindow(id string, idx int) error {
+	if c, ok := backend.(windowController); ok {
+		return c.MoveWindow(id, idx-1)
+	}
+	return wmctrlBackend{}.MoveWindow(id, idx-1)
+}
+
+// raiseWindow switches to w's workspace, unless it is sticky or already
+// active, and focuses it.
+func raiseWindow(w windowInfo) error {
+	if w.Desktop >= 0 {
+		if cur, err := getActiveWorkspaceIndex(); err != nil || cur != w.Desktop {
+			if err := switchWorkspace(w.Desktop + 1); err != nil {
+				return err
 			}
-			return nil
-		case 'G':
-			list.SetCurrentItem(list.GetItemCount() - 1)
-			return nil
-		case 'g':
-			list.SetCurrentItem(0)
-			return nil
-		case '?':
-			showModal(tui,
-				"Enter: Switch\n"+
-					"↑/↓ or j/k: Move\n"+
-					"R: Rename\n"+
-					"N: New Workspace\n"+
-					"Z: Toggle Dynamic\n"+
-					"X: Remove\n"+
-					"Shift+J/K: Rearrange\n"+
-					"G/g: Last/First\n"+
-					"Q/Esc: Quit",
-				"OK", nil)
-			return nil
 		}
-		return ev
-	})
+	}
+	return focusWindow(w.ID)
+}
 
-	flex := tview.NewFlex().SetDirection(tview.FlexRow)
-	flex.AddItem(head, 1, 1, false)
-	flex.AddItem(list, 0, 6, true)
-	flex.AddItem(foot, 1, 1, false)
+// findWindow returns the window whose title or class matches query, using
+// the same tiers as switching by name. With first, the first of several
+// matches is taken instead of reporting them as ambiguous.
+func findWindow(query string, first bool) (windowInfo, error) {
+	wins, err := listWindows()
+	if err != nil {
+		return windowInfo{}, err
+	}
+	hay := make([]string, len(wins))
+	for i, w := range wins {
+		hay[i] = w.Title + " " + w.Class
+	}
+	hits := matchNames(hay, query, false)
+	switch {
+	case len(hits) == 0:
+		return windowInfo{}, fmt.Errorf("no window matches %q", query)
+	case len(hits) == 1 || first:
+		return wins[hits[0]], nil
+	}
+	var cands []string
+	for _, i := range hits {
+		cands = append(cands, fmt.Sprintf("%s (%s)", wins[i].Title, friendlyClassName(wins[i].Class)))
+	}
+	return windowInfo{}, fmt.Errorf("%q is ambiguous: %s", query, strings.Join(cands, ", "))
+}
 
-	tui.layout = flex
-	app.SetRoot(flex, true).SetFocus(list)
-	return app.Run()
+// findAndFocus switches to the workspace of the window matching query and
+// focuses it.
+func findAndFocus(query string, first bool) error {
+	w, err := findWindow(query, first)
+	if err != nil {
+		return err
+	}
+	return raiseWindow(w)
 }
 
-func createDialog(refresh func(), tui *TUI) {
-	form := tview.NewForm()
-	form.SetBorder(true)
-	form.SetTitle("Create # of Workspaces")
+func renameLocal(index int, newName string) error {
+	if index < 1 {
+		return fmt.Errorf("invalid index: %d", index)
+	}
+	var oldName string
+	if err := updateConfig(func() error {
+		oldName = nameForIndex(index - 1)
+		ensureWorkspaces(index)
+		cfg.Workspaces[index-1].Name = newName
+		cfg.Workspaces[index-1].Auto = false
+		return nil
+	}); err != nil {
+		return err
+	}
+	runRenameHooks(index, oldName, newName)
+	return nil
+}
 
-	form.AddInputField("Count", "", 3, nil, nil)
-	form.AddButton("OK", func() {
-		c := form.GetFormItemByLabel("Count").(*tview.InputField).GetText()
-		n, err := strconv.Atoi(c)
-		if err == nil && n > 0 {
-			_ = createWorkspaces(n)
-			refresh()
+// renameAll renames workspaces from names, one per workspace in order. Blank
+// entries leave that workspace's name alone; workspaces past the end of
+// names are untouched. It returns one line per name that changed.
+func renameAll(names []string) ([]string, error) {
+	var changes []string
+	var renamed []int
+	var oldNames []string
+	err := updateConfig(func() error {
+		changes, renamed, oldNames = nil, nil, nil
+		for i, n := range names {
+			n = strings.TrimSpace(n)
+			if n == "" || n == nameForIndex(i) {
+				continue
+			}
+			ensureWorkspaces(i + 1)
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", formatIndex(i), cfg.Workspaces[i].Name, n))
+			renamed = append(renamed, i)
+			oldNames = append(oldNames, nameForIndex(i))
+			cfg.Workspaces[i].Name = n
+			cfg.Workspaces[i].Auto = false
 		}
-		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
-	})
-	form.AddButton("Cancel", func() {
-		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		return nil
 	})
-	tui.app.SetRoot(form, true).SetFocus(form)
+	if err == nil {
+		for j, i := range renamed {
+			runRenameHooks(i+1, oldNames[j], nameForIndex(i))
+		}
+	}
+	return changes, err
 }
 
-func toggleDynamic(tui *TUI, refresh func()) {
-	cur, err := getDynamic()
+// editNames opens the workspace names, one per line, in $VISUAL or $EDITOR
+// (vi without either) and returns the edited lines.
+func editNames() ([]string, error) {
+	sc, err := getSystemWorkspaceCount()
 	if err != nil {
-		showModal(tui, fmt.Sprintf("Error: %v", err), "OK", nil)
-		return
+		return nil, err
 	}
-	nv := !cur
-	if e := setDynamic(nv); e != nil {
-		showModal(tui, fmt.Sprintf("Error setting dynamic: %v", e), "OK", nil)
-		return
+	f, err := ioutil.TempFile("", "gnav-names-*.txt")
+	if err != nil {
+		return nil, err
 	}
-	refresh()
+	defer os.Remove(f.Name())
+	for i := 0; i < max(sc, len(cfg.Workspaces)); i++ {
+		fmt.Fprintln(f, nameForIndex(i))
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// Through the shell, so EDITOR may carry arguments ("code --wait").
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v", editor, err)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(b), "\n"), "\n"), nil
+}
 
-	msg := "Dynamic Workspaces = OFF"
-	if nv {
-		msg = "Dynamic Workspaces = ON"
+// setNote sets the note of the 1-based workspace index; an empty note
+// removes it.
+func setNote(index int, note string) error {
+	if index < 1 {
+		return fmt.Errorf("invalid index: %d", index)
 	}
-	showModal(tui, msg, "OK", nil)
+	return updateConfig(func() error {
+		ensureWorkspaces(index)
+		cfg.Workspaces[index-1].Note = note
+		return nil
+	})
 }
 
-func showModal(tui *TUI, msg, label string, done func()) {
-	m := tview.NewModal()
-	m.SetText(msg).AddButtons([]string{label})
-	m.SetDoneFunc(func(_ int, _ string) {
-		if done != nil {
-			done()
-		} else {
-			tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+// repairConfig replaces blank names left by hand-editing with their defaults
+// and, with prune, drops names beyond the system workspace count. It returns
+// one line per change and only saves when something changed.
+func repairConfig(prune bool) ([]string, error) {
+	sc := -1
+	if prune {
+		var err error
+		if sc, err = getSystemWorkspaceCount(); err != nil {
+			return nil, err
+		}
+	}
+	var changes []string
+	err := updateConfig(func() error {
+		changes = nil
+		for i, w := range cfg.Workspaces {
+			if strings.TrimSpace(w.Name) == "" {
+				cfg.Workspaces[i].Name = defaultName(i)
+				changes = append(changes, fmt.Sprintf("%d: blank name set to %q", i+1, cfg.Workspaces[i].Name))
+			}
+		}
+		if sc >= 0 && len(cfg.Workspaces) > sc {
+			for i := sc; i < len(cfg.Workspaces); i++ {
+				changes = append(changes, fmt.Sprintf("%d: removed %q (only %d workspaces)", i+1, cfg.Workspaces[i].Name, sc))
+			}
+			cfg.Workspaces = cfg.Workspaces[:sc]
+		}
+		if len(changes) == 0 {
+			return errUnchanged
 		}
+		return nil
 	})
-	tui.app.SetRoot(m, false).SetFocus(m)
+	return changes, err
 }
 
-// -----------------------------------------------------------------------------
-// renameDialog (original preserved)
-// -----------------------------------------------------------------------------
+// friendlyClassName maps a wmctrl "instance.Class" string through the
+// class_name_map config, falling back to the raw class. The full string is
+// looked up first, then the class, then the instance; for each, an exact
+// key wins over keys differing only in case, taken in sorted order.
+func friendlyClassName(wmClass string) string {
+	instance, class := wmClass, wmClass
+	if i := strings.LastIndex(wmClass, "."); i >= 0 {
+		instance, class = wmClass[:i], wmClass[i+1:]
+	}
+	keys := make([]string, 0, len(cfg.ClassNameMap))
+	for k := range cfg.ClassNameMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, name := range []string{wmClass, class, instance} {
+		if v, ok := cfg.ClassNameMap[name]; ok {
+			return v
+		}
+		for _, k := range keys {
+			if strings.EqualFold(k, name) {
+				return cfg.ClassNameMap[k]
+			}
+		}
+	}
+	return class
+}
 
-func renameDialog(idx int, refresh func(), tui *TUI) {
-	form := tview.NewForm()
-	form.SetBorder(true)
-	form.SetTitle(fmt.Sprintf("Rename Local #%d", idx))
+// autoNameActive renames the active workspace after its primary window: the
+// focused window if it lives there, otherwise the first one wmctrl lists.
+func autoNameActive() (string, error) {
+	idx, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return "", err
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return "", err
+	}
+	activeID, _ := getActiveWindowID()
+	var primary *windowInfo
+	for i := range wins {
+		if wins[i].Desktop != idx {
+			continue
+		}
+		if primary == nil {
+			primary = &wins[i]
+		}
+		if id, err := strconv.ParseUint(wins[i].ID, 0, 64); err == nil && id == activeID {
+			primary = &wins[i]
+			break
+		}
+	}
+	if primary == nil {
+		return "", errors.New("no windows on the active workspace")
+	}
+	name := friendlyClassName(primary.Class)
+	return name, renameLocal(idx+1, name)
+}
+
+// errDynamicCreate is returned by createWorkspaces when growing would have
+// to turn dynamic workspaces off and static wasn't asked for.
+var errDynamicCreate = errors.New("dynamic workspaces are on; pass --static to turn them off and create static workspaces")
+
+// createWorkspaces grows to num workspaces. While dynamic workspaces are on
+// GNOME manages the count itself, so growing then takes static, which turns
+// them off; the previous mode comes back once the extra workspaces are
+// removed again (see restoreDynamic).
+func createWorkspaces(num int, static bool) error {
+	if num < 1 {
+		return errors.New("workspaces must be >= 1")
+	}
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return err
+	}
+	if num > sc {
+		dyn, _ := getDynamic()
+		if dyn && !static {
+			return errDynamicCreate
+		}
+		invalidateMenuCache()
+		if err := backend.SetCount(num); err != nil {
+			return err
+		}
+		if dyn {
+			if err := rememberDynamic(sc); err != nil {
+				return err
+			}
+			if err := backend.SetDynamic(false); err != nil {
+				return err
+			}
+		}
+	}
+	if err := updateConfig(func() error {
+		ensureWorkspaces(num)
+		if cfg.Count > 0 && num > sc {
+			cfg.Count = num
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if num > sc {
+		runCreateHooks(sc+1, num, num)
+	}
+	return nil
+}
+
+// closeEmptyWorkspaces removes the trailing workspaces that hold no windows,
+// never the active one or the first, and returns how many it removed.
+// Workspaces between occupied ones stay, as removing them would move
+// windows to other workspaces.
+func closeEmptyWorkspaces() (int, error) {
+	if dyn, _ := getDynamic(); dyn {
+		return 0, errors.New("dynamic workspaces are on; empty ones are already removed")
+	}
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return 0, err
+	}
+	active, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return 0, err
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[int]bool)
+	for _, w := range wins {
+		used[w.Desktop] = true
+	}
+	n := sc
+	for n > 1 && n-1 > active && !used[n-1] {
+		n--
+	}
+	if n == sc {
+		return 0, nil
+	}
+	invalidateMenuCache()
+	if err := backend.SetCount(n); err != nil {
+		return 0, err
+	}
+	if err := updateConfig(func() error {
+		if cfg.Count == 0 {
+			return errUnchanged
+		}
+		cfg.Count = n
+		return nil
+	}); err != nil {
+		return sc - n, err
+	}
+	return sc - n, restoreDynamic()
+}
+
+// watchConfig calls changed whenever the config file is written, replaced
+// or removed, until stop is closed, so edits made in an editor or by a
+// dotfiles sync show up without a restart. The directory is watched rather
+// than the file because saves replace the file with a rename. A symlinked
+// config, which saves write through to, has its target's directory watched
+// as well.
+func watchConfig(stop <-chan struct{}, changed func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.Add(filepath.Dir(configFile)); err != nil {
+		return err
+	}
+	names := map[string]bool{filepath.Clean(configFile): true}
+	if real, err := filepath.EvalSymlinks(configFile); err == nil && !names[real] {
+		if err := w.Add(filepath.Dir(real)); err != nil {
+			return err
+		}
+		names[real] = true
+	}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if names[filepath.Clean(ev.Name)] && !ev.Has(fsnotify.Chmod) {
+				changed()
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// TUI
+// -----------------------------------------------------------------------------
+
+// tuiKeys documents the TUI bindings, for the ? overlay and `gnav keys`.
+var tuiKeys = []struct{ key, action string }{
+	{"Enter", "Switch"},
+	{"↑/↓ or j/k", "Move"},
+	{"R", "Rename"},
+	{"E", "Edit Note"},
+	{"N", "New Workspace"},
+	{"I", "Insert Workspace Above"},
+	{"Z", "Toggle Dynamic"},
+	{"X", "Delete Workspace"},
+	{"M", "Move Window Here"},
+	{"P", "Toggle Windows Pane"},
+	{"Tab", "Focus Windows Pane"},
+	{"Enter (pane)", "Focus Window"},
+	{"M (pane)", "Move Window To…"},
+	{"Shift+J/K", "Rearrange"},
+	{"O", "Order by Recency"},
+	{"G/g", "Last/First"},
+	{"Q/Esc", "Quit"},
+}
+
+// keyCheatsheet renders tuiKeys as an aligned two-column table. A positive
+// width truncates the action column so rows fit.
+func keyCheatsheet(width int) string {
+	keyW := 0
+	for _, k := range tuiKeys {
+		keyW = max(keyW, tview.TaggedStringWidth(k.key))
+	}
+	var b strings.Builder
+	for _, k := range tuiKeys {
+		action := k.action
+		if room := width - keyW - 2; width > 0 && len([]rune(action)) > room {
+			action = string([]rune(action)[:max(room, 0)])
+		}
+		pad := keyW - tview.TaggedStringWidth(k.key)
+		b.WriteString(k.key + strings.Repeat(" ", pad) + "  " + action + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type TUI struct {
+	app       *tview.Application
+	layout    *tview.Flex
+	list      *tview.List
+	renameBox *tview.InputField
+	foot      *tview.TextView
+}
+
+// tuiEntry is the list text of the 0-based workspace i: its index, then the
+// configured icon, if any, and its name.
+func tuiEntry(i, count int, dyn bool) string {
+	nm := displayName(i, count, dyn)
+	if icon := iconText(workspaceMeta(i).Icon); icon != "" && !(dyn && i == count-1) {
+		nm = icon + " " + nm
+	}
+	return fmt.Sprintf("(%s) %s", formatIndex(i), nm)
+}
+
+// tuiColor escapes text for a tview list row and colors it with the
+// workspace's color, if any.
+func tuiColor(text, color string) string {
+	text = tview.Escape(text)
+	if color == "" {
+		return text
+	}
+	return "[" + color + "]" + text + "[-]"
+}
+
+// runTUI starts the interactive manager. With switchAndQuit set, selecting a
+// workspace switches to it and exits, turning the TUI into a one-shot picker.
+func runTUI(switchAndQuit bool) error {
+	setTUIViewTheme()
+	sc, _ := getSystemWorkspaceCount()
+	activeIdx, _ := getActiveWorkspaceIndex()
+
+	app := tview.NewApplication()
+
+	head := tview.NewTextView()
+	title := "GNAV TUI"
+	if profile != "" {
+		title += " · " + profile
+	}
+	head.SetText(title).SetTextAlign(tview.AlignCenter)
+
+	foot := tview.NewTextView()
+	foot.SetText("[↑/↓] Move  [Enter] Switch  [X] Delete  [?] More  [Q/Esc] Quit")
+
+	list := tview.NewList()
+	list.SetBorder(true)
+	list.SetTitle(" Workspaces ")
+	list.ShowSecondaryText(false)
+	styleList(list)
+
+	preview := tview.NewList()
+	preview.SetBorder(true)
+	preview.SetTitle(" Windows ")
+	preview.ShowSecondaryText(false)
+	styleList(preview)
+	// paneWins backs the rows of the windows pane.
+	var paneWins []windowInfo
+
+	body := tview.NewFlex()
+	body.AddItem(list, 0, 1, true)
+	showPreview := cfg.TUIPreview
+	if showPreview {
+		body.AddItem(preview, 0, 1, false)
+	}
+
+	dyn, _ := getDynamic()
+
+	// order maps list rows to 0-based workspaces. It is the identity unless
+	// the list is ordered by recency.
+	var order []int
+	byRecency := false
+	fill := func(count, active int, dyn bool) {
+		order = order[:0]
+		if byRecency {
+			order = mruOrder(loadHistory(), count)
+		} else {
+			for i := 0; i < count; i++ {
+				order = append(order, i)
+			}
+		}
+		var items []string
+		maxLen := 0
+		for _, i := range order {
+			entry := tuiEntry(i, count, dyn)
+			if len(entry) > maxLen {
+				maxLen = len(entry)
+			}
+			items = append(items, entry)
+		}
+		list.Clear()
+		// Notes go in the secondary line, which is only shown when some
+		// workspace has one so the list stays compact otherwise.
+		notes := false
+		for _, i := range order {
+			notes = notes || workspaceMeta(i).Note != ""
+		}
+		list.ShowSecondaryText(notes)
+		activeRow := 0
+		for row, entry := range items {
+			if order[row] == active {
+				entry = fmt.Sprintf("%-*s  *", maxLen, entry)
+				activeRow = row
+			}
+			meta := workspaceMeta(order[row])
+			list.AddItem(tuiColor(entry, meta.Color), "    "+tview.Escape(meta.Note), 0, nil)
+		}
+		list.SetCurrentItem(activeRow)
+	}
+	fill(sc, activeIdx, dyn)
+
+	tui := &TUI{
+		app:    app,
+		layout: nil,
+		list:   list,
+		foot:   foot,
+	}
+
+	reload := func() {
+		_ = loadConfig()
+		s, _ := getSystemWorkspaceCount()
+		aIdx, _ := getActiveWorkspaceIndex()
+		dynRefresh, _ := getDynamic()
+		fill(s, aIdx, dynRefresh)
+	}
+
+	// current returns the 0-based workspace under the cursor.
+	current := func() int {
+		if row := list.GetCurrentItem(); row < len(order) {
+			return order[row]
+		}
+		return 0
+	}
+
+	startInlineRename := func(idx int) {
+		cur := nameForIndex(idx - 1)
+		tui.renameBox = tview.NewInputField().SetText(cur)
+		tui.renameBox.SetDoneFunc(func(key tcell.Key) {
+			switch key {
+			case tcell.KeyEnter:
+				newN := tui.renameBox.GetText()
+				tui.layout.RemoveItem(tui.renameBox)
+				tui.layout.AddItem(tui.foot, 1, 1, false)
+				tui.app.SetFocus(tui.list)
+				if newN != "" {
+					saveGuarded(tui, func() {
+						_ = renameLocal(idx, newN)
+						reload()
+					}, reload)
+				}
+			case tcell.KeyEsc:
+				tui.layout.RemoveItem(tui.renameBox)
+				tui.layout.AddItem(tui.foot, 1, 1, false)
+				tui.app.SetFocus(tui.list)
+				// Catch up on refreshes skipped while renaming.
+				reload()
+			}
+		})
+		tui.layout.RemoveItem(tui.foot)
+		tui.layout.AddItem(tui.renameBox, 1, 1, true)
+		tui.app.SetFocus(tui.renameBox)
+	}
+
+	// The preview is fetched after the cursor settles so scrolling through
+	// the list doesn't spawn a wmctrl per row.
+	var previewTimer *time.Timer
+	schedulePreview := func(index int) {
+		if previewTimer != nil {
+			previewTimer.Stop()
+		}
+		ws := index
+		if index < len(order) {
+			ws = order[index]
+		}
+		previewTimer = time.AfterFunc(previewDelay, func() {
+			wins, err := windowsOn(ws)
+			app.QueueUpdateDraw(func() {
+				if list.GetCurrentItem() != index {
+					return
+				}
+				preview.Clear()
+				paneWins = wins
+				switch {
+				case err != nil:
+					preview.AddItem(fmt.Sprintf("Error: %v", err), "", 0, nil)
+				case len(wins) == 0:
+					preview.AddItem("(no windows)", "", 0, nil)
+				}
+				for _, w := range wins {
+					preview.AddItem(fmt.Sprintf("%s (%s)", w.Title, friendlyClassName(w.Class)), "", 0, nil)
+				}
+			})
+		})
+	}
+	refreshPane := func() {
+		reload()
+		if showPreview {
+			schedulePreview(list.GetCurrentItem())
+		}
+	}
+
+	preview.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		if index >= len(paneWins) {
+			return
+		}
+		if err := raiseWindow(paneWins[index]); err != nil {
+			showModal(tui, fmt.Sprintf("Error focusing window: %v", err), "OK", nil)
+			return
+		}
+		if switchAndQuit {
+			app.Stop()
+			return
+		}
+		app.SetFocus(list)
+		refreshPane()
+	})
+	preview.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyTab, tcell.KeyBacktab, tcell.KeyEsc:
+			app.SetFocus(list)
+			return nil
+		}
+		switch ev.Rune() {
+		case 'q', 'Q':
+			app.Stop()
+			return nil
+		case 'm', 'M':
+			if i := preview.GetCurrentItem(); i < len(paneWins) {
+				moveWindowDialog(paneWins[i], refreshPane, tui)
+			}
+			return nil
+		}
+		return ev
+	})
+	list.SetChangedFunc(func(index int, _, _ string, _ rune) {
+		if showPreview {
+			schedulePreview(index)
+		}
+	})
+	if showPreview {
+		schedulePreview(list.GetCurrentItem())
+	}
+
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		sCount, _ := getSystemWorkspaceCount()
+		if ws := current(); ws < sCount {
+			switchWorkspace(ws + 1)
+			if switchAndQuit {
+				app.Stop()
+			}
+		}
+	})
+
+	list.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEsc:
+			app.Stop()
+			return nil
+		case tcell.KeyUp, tcell.KeyDown:
+			return ev
+		case tcell.KeyTab:
+			if showPreview {
+				app.SetFocus(preview)
+			}
+			return nil
+		}
+		switch ev.Rune() {
+		case 'q', 'Q':
+			app.Stop()
+			return nil
+		case 'j':
+			n := (list.GetCurrentItem() + 1) % list.GetItemCount()
+			list.SetCurrentItem(n)
+			return nil
+		case 'k':
+			n := (list.GetCurrentItem() - 1 + list.GetItemCount()) % list.GetItemCount()
+			list.SetCurrentItem(n)
+			return nil
+		case 'r', 'R':
+			startInlineRename(current() + 1)
+			return nil
+		case 'e', 'E':
+			noteDialog(current()+1, reload, tui)
+			return nil
+		case 'n', 'N':
+			createDialog(reload, tui)
+			return nil
+		case 'i', 'I':
+			insertDialog(current()+1, reload, tui)
+			return nil
+		case 'z', 'Z':
+			toggleDynamic(tui, reload)
+			return nil
+		case 'J':
+			i := list.GetCurrentItem()
+			if !byRecency && i < list.GetItemCount()-1 {
+				swapInTUI(tui, i, i+1, reload)
+			}
+			return nil
+		case 'K':
+			i := list.GetCurrentItem()
+			if !byRecency && i > 0 {
+				swapInTUI(tui, i, i-1, reload)
+			}
+			return nil
+		case 'x', 'X':
+			row, i := list.GetCurrentItem(), current()
+			deleteDialog(i+1, func() {
+				reload()
+				if row > list.GetItemCount()-1 {
+					row = list.GetItemCount() - 1
+				}
+				if row < 0 {
+					row = 0
+				}
+				list.SetCurrentItem(row)
+			}, tui)
+			return nil
+		case 'm', 'M':
+			row, i := list.GetCurrentItem(), current()
+			if err := moveActiveWindow(i + 1); err != nil {
+				showModal(tui, fmt.Sprintf("Error moving window: %v", err), "OK", nil)
+				return nil
+			}
+			reload()
+			list.SetCurrentItem(row)
+			showModal(tui, fmt.Sprintf("Moved window to %s", nameForIndex(i)), "OK", nil)
+			return nil
+		case 'p', 'P':
+			showPreview = !showPreview
+			if showPreview {
+				body.AddItem(preview, 0, 1, false)
+				schedulePreview(list.GetCurrentItem())
+			} else {
+				body.RemoveItem(preview)
+			}
+			return nil
+		case 'o', 'O':
+			byRecency = !byRecency
+			if byRecency {
+				list.SetTitle(" Recent Workspaces ")
+			} else {
+				list.SetTitle(" Workspaces ")
+			}
+			reload()
+			return nil
+		case 'G':
+			list.SetCurrentItem(list.GetItemCount() - 1)
+			return nil
+		case 'g':
+			list.SetCurrentItem(0)
+			return nil
+		case '?':
+			showHelp(tui, keyCheatsheet(0))
+			return nil
+		}
+		return ev
+	})
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow)
+	flex.AddItem(head, 1, 1, false)
+	flex.AddItem(body, 0, 6, true)
+	flex.AddItem(foot, 1, 1, false)
+
+	tui.layout = flex
+	app.SetRoot(flex, true).SetFocus(list)
+
+	stop := make(chan struct{})
+	refresh := func() {
+		app.QueueUpdateDraw(func() {
+			if tui.renameBox != nil && tui.renameBox.HasFocus() {
+				return
+			}
+			reload()
+		})
+	}
+	go pollWorkspaces(stop, sc, activeIdx, refresh)
+	go func() { _ = watchConfig(stop, refresh) }()
+	err := app.Run()
+	close(stop)
+	return err
+}
+
+const pollInterval = 750 * time.Millisecond
+
+// pollWorkspaces calls changed whenever the workspace count or the active
+// workspace differs from the last seen values, until stop is closed. It
+// keeps the TUI current when workspaces change outside of gnav.
+func pollWorkspaces(stop <-chan struct{}, count, active int, changed func()) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		c, err := getSystemWorkspaceCount()
+		if err != nil {
+			continue
+		}
+		a, err := getActiveWorkspaceIndex()
+		if err != nil {
+			continue
+		}
+		if c != count || a != active {
+			count, active = c, a
+			changed()
+		}
+	}
+}
+
+const previewDelay = 250 * time.Millisecond
+
+// windowsOn lists the windows on the 0-based workspace idx.
+func windowsOn(idx int) ([]windowInfo, error) {
+	wins, err := listWindows()
+	if err != nil {
+		return nil, err
+	}
+	var on []windowInfo
+	for _, w := range wins {
+		if w.Desktop == idx {
+			on = append(on, w)
+		}
+	}
+	return on, nil
+}
+
+// moveWindowDialog asks for a workspace, by index or name, to send w to.
+func moveWindowDialog(w windowInfo, refresh func(), tui *TUI) {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(fmt.Sprintf("Move %q", w.Title))
+
+	form.AddInputField("Workspace", "", 20, nil, nil)
+	form.AddButton("OK", func() {
+		arg := form.GetFormItemByLabel("Workspace").(*tview.InputField).GetText()
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		if arg == "" {
+			return
+		}
+		idx, _, err := resolveWorkspace(arg, false)
+		if err == nil {
+			err = moveWindow(w.ID, idx)
+		}
+		if err != nil {
+			showModal(tui, fmt.Sprintf("Error moving window: %v", err), "OK", nil)
+			return
+		}
+		refresh()
+	})
+	form.AddButton("Cancel", func() {
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	})
+	tui.app.SetRoot(form, true).SetFocus(form)
+}
+
+func createDialog(refresh func(), tui *TUI) {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle("Create # of Workspaces")
+
+	form.AddInputField("Count", "", 3, nil, nil)
+	form.AddButton("OK", func() {
+		c := form.GetFormItemByLabel("Count").(*tview.InputField).GetText()
+		n, err := strconv.Atoi(c)
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		if err != nil || n < 1 {
+			return
+		}
+		create := func(static bool) {
+			saveGuarded(tui, func() {
+				_ = createWorkspaces(n, static)
+				refresh()
+			}, refresh)
+		}
+		sc, _ := getSystemWorkspaceCount()
+		if dyn, _ := getDynamic(); !dyn || n <= sc {
+			create(false)
+			return
+		}
+		m := tview.NewModal()
+		m.SetText(fmt.Sprintf("Dynamic workspaces are on. Turn them off to create %d static workspaces? They come back on once the extra workspaces are removed.", n)).
+			AddButtons([]string{"Turn off", "Cancel"})
+		m.SetDoneFunc(func(_ int, label string) {
+			tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+			if label == "Turn off" {
+				create(true)
+			}
+		})
+		tui.app.SetRoot(m, false).SetFocus(m)
+	})
+	form.AddButton("Cancel", func() {
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	})
+	tui.app.SetRoot(form, true).SetFocus(form)
+}
+
+// swapInTUI exchanges the workspaces in list rows from and to, windows and
+// all, and keeps the moved workspace highlighted.
+func swapInTUI(tui *TUI, from, to int, refresh func()) {
+	saveGuarded(tui, func() {
+		err := swapWorkspaces(from+1, to+1)
+		refresh()
+		if err != nil {
+			showModal(tui, fmt.Sprintf("Error moving workspace: %v", err), "OK", nil)
+			return
+		}
+		tui.list.SetCurrentItem(to)
+	}, refresh)
+}
+
+// insertDialog asks for the name of a workspace to insert at the 1-based
+// position idx, above the highlighted one.
+func insertDialog(idx int, refresh func(), tui *TUI) {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(fmt.Sprintf("Insert Workspace at #%s", formatIndex(idx-1)))
+
+	form.AddInputField("Name", "", 20, nil, nil)
+	form.AddButton("OK", func() {
+		name := strings.TrimSpace(form.GetFormItemByLabel("Name").(*tview.InputField).GetText())
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		saveGuarded(tui, func() {
+			err := insertWorkspace(idx, name)
+			refresh()
+			if err != nil {
+				showModal(tui, fmt.Sprintf("Error inserting workspace: %v", err), "OK", nil)
+			}
+		}, refresh)
+	})
+	form.AddButton("Cancel", func() {
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	})
+	tui.app.SetRoot(form, true).SetFocus(form)
+}
+
+// deleteDialog asks before deleting the 1-based workspace idx, which moves
+// its windows to the workspace before it.
+func deleteDialog(idx int, refresh func(), tui *TUI) {
+	m := tview.NewModal()
+	m.SetText(fmt.Sprintf("Delete workspace %s (%s)? Its windows move to %s.",
+		formatIndex(idx-1), nameForIndex(idx-1), nameForIndex(deleteTarget(idx)-1))).
+		AddButtons([]string{"Delete", "Cancel"})
+	m.SetDoneFunc(func(_ int, label string) {
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		if label != "Delete" {
+			return
+		}
+		saveGuarded(tui, func() {
+			_, err := deleteWorkspace(idx, 0)
+			refresh()
+			if err != nil {
+				showModal(tui, fmt.Sprintf("Error deleting workspace: %v", err), "OK", nil)
+			}
+		}, refresh)
+	})
+	tui.app.SetRoot(m, false).SetFocus(m)
+}
+
+func toggleDynamic(tui *TUI, refresh func()) {
+	cur, err := getDynamic()
+	if err != nil {
+		showModal(tui, fmt.Sprintf("Error: %v", err), "OK", nil)
+		return
+	}
+	nv := !cur
+	if e := setDynamic(nv); e != nil {
+		showModal(tui, fmt.Sprintf("Error setting dynamic: %v", e), "OK", nil)
+		return
+	}
+	refresh()
+
+	msg := "Dynamic Workspaces = OFF"
+	if nv {
+		msg = "Dynamic Workspaces = ON"
+	}
+	showModal(tui, msg, "OK", nil)
+}
+
+// showHelp displays text in a scrollable overlay that is never taller than
+// the terminal. When the text doesn't fit, the title shows which lines are
+// visible; with no room left for a border the bare text is drawn.
+func showHelp(tui *TUI, text string) {
+	lines := strings.Split(text, "\n")
+	width := 0
+	for _, l := range lines {
+		if w := tview.TaggedStringWidth(l); w > width {
+			width = w
+		}
+	}
+
+	view := tview.NewTextView().SetWrap(false).SetText(text)
+	row := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(view, width+4, 0, true).
+		AddItem(nil, 0, 1, false)
+	overlay := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(row, len(lines)+2, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	tui.app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		w, h := screen.Size()
+		height := min(len(lines)+2, h)
+		border := height >= 3
+		visible := height
+		if border {
+			visible -= 2
+		}
+		view.SetBorder(border)
+		view.SetTitle(" Help ")
+		if visible < len(lines) {
+			off, _ := view.GetScrollOffset()
+			view.SetTitle(fmt.Sprintf(" Help %d-%d/%d ",
+				off+1, min(off+visible, len(lines)), len(lines)))
+		}
+		overlay.ResizeItem(row, height, 0)
+		row.ResizeItem(view, min(width+4, w), 0)
+		return false
+	})
+
+	done := func() {
+		tui.app.SetBeforeDrawFunc(nil)
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	}
+	view.SetDoneFunc(func(tcell.Key) { done() })
+	view.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Rune() {
+		case 'q', 'Q', '?':
+			done()
+			return nil
+		}
+		return ev
+	})
+	tui.app.SetRoot(overlay, true).SetFocus(view)
+}
+
+// saveGuarded runs commit unless the config file changed on disk since it was
+// last loaded, in which case the user chooses between reloading the file
+// (discarding this edit) and overwriting it.
+func saveGuarded(tui *TUI, commit func(), refresh func()) {
+	if !configChangedOnDisk() {
+		commit()
+		return
+	}
+	m := tview.NewModal()
+	m.SetText("Config changed on disk; reload / overwrite?").
+		AddButtons([]string{"Reload", "Overwrite"})
+	m.SetDoneFunc(func(_ int, label string) {
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		if label == "Overwrite" {
+			commit()
+		} else {
+			refresh()
+		}
+	})
+	tui.app.SetRoot(m, false).SetFocus(m)
+}
+
+func showModal(tui *TUI, msg, label string, done func()) {
+	m := tview.NewModal()
+	m.SetText(msg).AddButtons([]string{label})
+	m.SetDoneFunc(func(_ int, _ string) {
+		if done != nil {
+			done()
+		} else {
+			tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		}
+	})
+	tui.app.SetRoot(m, false).SetFocus(m)
+}
+
+// -----------------------------------------------------------------------------
+// renameDialog (original preserved)
+// -----------------------------------------------------------------------------
+
+func renameDialog(idx int, refresh func(), tui *TUI) {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(fmt.Sprintf("Rename Local #%d", idx))
+
+	cur := nameForIndex(idx - 1)
+
+	form.AddInputField("Name", cur, 20, nil, nil)
+	form.AddButton("OK", func() {
+		newN := form.GetFormItemByLabel("Name").(*tview.InputField).GetText()
+		if newN != "" {
+			_ = renameLocal(idx, newN)
+			refresh()
+		}
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	})
+	form.AddButton("Cancel", func() {
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	})
+	tui.app.SetRoot(form, true).SetFocus(form)
+}
+
+func noteDialog(idx int, refresh func(), tui *TUI) {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(fmt.Sprintf("Note for %s", nameForIndex(idx-1)))
+
+	form.AddInputField("Note", workspaceMeta(idx-1).Note, 40, nil, nil)
+	form.AddButton("OK", func() {
+		note := form.GetFormItemByLabel("Note").(*tview.InputField).GetText()
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+		saveGuarded(tui, func() {
+			_ = setNote(idx, strings.TrimSpace(note))
+			refresh()
+		}, refresh)
+	})
+	form.AddButton("Cancel", func() {
+		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	})
+	tui.app.SetRoot(form, true).SetFocus(form)
+}
+
+// -----------------------------------------------------------------------------
+// JSON list
+// -----------------------------------------------------------------------------
+
+// listEntry is one workspace in `list --json`. Index is 1-based.
+type listEntry struct {
+	Index              int    `json:"index"`
+	Name               string `json:"name"`
+	Active             bool   `json:"active"`
+	Windows            int    `json:"windows"`
+	Icon               string `json:"icon,omitempty"`
+	Color              string `json:"color,omitempty"`
+	Note               string `json:"note,omitempty"`
+	DynamicPlaceholder bool   `json:"dynamic_placeholder"`
+
+	// label, when set, is shown by the bars in place of the name.
+	label string
+}
+
+// listEntries describes count workspaces given the 0-based active index,
+// whether dynamic workspaces are on and the window count per 0-based
+// workspace, using the same trailing-slot rule as the TUI and menus.
+func listEntries(count, active int, dyn bool, windows map[int]int) []listEntry {
+	entries := make([]listEntry, 0, count)
+	for i := 0; i < count; i++ {
+		e := listEntry{
+			Index:              i + 1,
+			Name:               displayName(i, count, dyn),
+			Active:             i == active,
+			Windows:            windows[i],
+			DynamicPlaceholder: dyn && i == count-1,
+		}
+		if !e.DynamicPlaceholder {
+			e.Icon = workspaceMeta(i).Icon
+			e.Color = workspaceMeta(i).Color
+			e.Note = workspaceMeta(i).Note
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Label is the index as displayed, following index_base and index_style.
+// It is meant for --format templates.
+func (e listEntry) Label() string {
+	return formatIndex(e.Index - 1)
+}
+
+// fetchEntries lists the workspaces through the daemon when one is running
+// and from the backend otherwise.
+func fetchEntries() ([]listEntry, error) {
+	var entries []listEntry
+	ok, err := callDaemon(daemonRequest{Cmd: "list"}, &entries)
+	if err != nil || ok {
+		return entries, err
+	}
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return nil, err
+	}
+	activeIdx, _ := getActiveWorkspaceIndex()
+	dyn, _ := getDynamic()
+	counts, _ := windowCounts()
+	return listEntries(sc, activeIdx, dyn, counts), nil
+}
+
+// entryLine renders e the way `list` prints it, or through tmpl when set.
+func entryLine(e listEntry, tmpl *template.Template) (string, error) {
+	if tmpl != nil {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, e); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+	name := e.Name
+	if e.DynamicPlaceholder {
+		name = nameForIndex(e.Index - 1)
+	}
+	if icon := iconText(e.Icon); icon != "" {
+		name = icon + " " + name
+	}
+	return fmt.Sprintf("[%s] %s", e.Label(), name), nil
+}
+
+// parseFormat compiles a --format template; an empty format yields nil.
+func parseFormat(format string) (*template.Template, error) {
+	if format == "" {
+		return nil, nil
+	}
+	if outputFormat == "json" {
+		return nil, errors.New("--format and --output json are mutually exclusive")
+	}
+	return template.New("format").Parse(format)
+}
+
+// windowEntry is one window in `windows --json`. Workspace is 1-based, or 0
+// for sticky windows shown on every workspace.
+type windowEntry struct {
+	ID        string `json:"id"`
+	Workspace int    `json:"workspace"`
+	Class     string `json:"class"`
+	Title     string `json:"title"`
+}
+
+// printWindows prints the windows grouped under each of count workspaces,
+// followed by the sticky ones.
+func printWindows(wins []windowInfo, count int) {
+	byDesktop := make(map[int][]windowInfo)
+	for _, w := range wins {
+		byDesktop[w.Desktop] = append(byDesktop[w.Desktop], w)
+	}
+	group := func(header string, ws []windowInfo) {
+		fmt.Println(header)
+		for _, w := range ws {
+			fmt.Printf("    %s  %s\n", w.Class, w.Title)
+		}
+	}
+	for i := 0; i < count; i++ {
+		group(fmt.Sprintf("[%s] %s", formatIndex(i), nameForIndex(i)), byDesktop[i])
+	}
+	if sticky := byDesktop[-1]; len(sticky) > 0 {
+		group("[*] All workspaces", sticky)
+	}
+}
+
+// windowCounts returns the number of windows on each 0-based workspace.
+// Sticky windows are not counted. The map is empty, never nil, when windows
+// can't be listed, so listings can ignore err and show no counts.
+func windowCounts() (map[int]int, error) {
+	counts := make(map[int]int)
+	wins, err := listWindows()
+	if err != nil {
+		return counts, err
+	}
+	for _, w := range wins {
+		if w.Desktop >= 0 {
+			counts[w.Desktop]++
+		}
+	}
+	return counts, nil
+}
+
+// -----------------------------------------------------------------------------
+// Status report
+// -----------------------------------------------------------------------------
+
+// statusReport compares the config with the system. Indices are 1-based;
+// Active is 0 when the active workspace can't be determined.
+type statusReport struct {
+	SystemCount   int   `json:"system_count"`
+	ConfigNames   int   `json:"config_names"`
+	FallbackNames []int `json:"fallback_names"`
+	Dynamic       bool  `json:"dynamic"`
+	Active        int   `json:"active"`
+}
+
+func collectStatus() (*statusReport, error) {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return nil, err
+	}
+	r := &statusReport{
+		SystemCount:   sc,
+		ConfigNames:   len(cfg.Workspaces),
+		FallbackNames: []int{},
+	}
+	for i := 0; i < sc; i++ {
+		if i >= len(cfg.Workspaces) || strings.TrimSpace(cfg.Workspaces[i].Name) == "" {
+			r.FallbackNames = append(r.FallbackNames, i+1)
+		}
+	}
+	r.Dynamic, _ = getDynamic()
+	if idx, err := getActiveWorkspaceIndex(); err == nil {
+		r.Active = idx + 1
+	}
+	return r, nil
+}
+
+func printStatus(r *statusReport) {
+	fmt.Printf("workspaces: %d on system, %d named in config\n", r.SystemCount, r.ConfigNames)
+	if len(r.FallbackNames) == 0 {
+		fmt.Println("fallback:   none")
+	} else {
+		var idx []string
+		for _, i := range r.FallbackNames {
+			idx = append(idx, formatIndex(i-1))
+		}
+		fmt.Printf("fallback:   %s\n", strings.Join(idx, ", "))
+	}
+	if r.Dynamic {
+		fmt.Println("dynamic:    on")
+	} else {
+		fmt.Println("dynamic:    off")
+	}
+	if r.Active > 0 {
+		fmt.Printf("active:     %s (%s)\n", formatIndex(r.Active-1), nameForIndex(r.Active-1))
+	} else {
+		fmt.Println("active:     unknown")
+	}
+}
+
+// -----------------------------------------------------------------------------
+// CLI helpers
+// -----------------------------------------------------------------------------
+
+// outputFormat is the global --output flag: "text" or "json".
+var outputFormat = "text"
+
+// backendFlag is the global --backend flag, which overrides the backend
+// config key.
+var backendFlag string
+
+// jsonOutput reports whether a read command should print JSON, either
+// because of --output json or its own --json flag.
+func jsonOutput(flag bool) bool {
+	return flag || outputFormat == "json"
+}
+
+// confirm asks a yes/no question on the terminal, after a line on the
+// workspace state the change would affect. When stdin is not a terminal it
+// answers yes without printing anything, so scripts are never left waiting
+// for input.
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return true
+	}
+	fmt.Fprintln(os.Stderr, workspaceSummary())
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// workspaceSummary describes the current workspace and window state in one
+// line, for prompts about changes that affect it.
+func workspaceSummary() string {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return fmt.Sprintf("workspace state unavailable: %v", err)
+	}
+	dyn, _ := getDynamic()
+	mode := "static"
+	if dyn {
+		mode = "dynamic"
+	}
+	summary := fmt.Sprintf("%d %s workspaces", sc, mode)
+	if wins, err := listWindows(); err == nil {
+		summary += fmt.Sprintf(", %d windows", len(wins))
+	}
+	if idx, err := getActiveWorkspaceIndex(); err == nil {
+		summary += fmt.Sprintf(", active: %s", nameForIndex(idx))
+	}
+	return summary
+}
+
+// -----------------------------------------------------------------------------
+// Main + cobra
+// -----------------------------------------------------------------------------
+
+// setup loads the config and picks the backend it asks for. It runs once
+// the flags are parsed, since --config may move the config file.
+func setup() {
+	configErr = loadConfig()
+	if m := nameSyncMode(); m == nameSyncPull || m == nameSyncMerge {
+		_, _ = syncNames(m)
+	}
+
+	name := cfg.Backend
+	if backendFlag != "" {
+		name = backendFlag
+	}
+	b, err := backendByName(name, os.Getenv, exec.LookPath)
+	if err != nil {
+		b = unavailableBackend{err}
+	}
+	backend = b
+}
+
+func main() {
+	var switchAndQuit bool
+	root := &cobra.Command{
+		Use: "gnav",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runTUI(switchAndQuit)
+		},
+	}
+	root.Flags().BoolVar(&switchAndQuit, "switch-and-quit", false,
+		"exit the TUI after switching workspace")
+	root.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text",
+		"output format of read commands: text or json")
+	root.PersistentFlags().StringVar(&backendFlag, "backend", "",
+		"backend to use instead of the backend config key (auto, i3, sway, hyprland, kde, gnome-shell, x11, wmctrl)")
+	var configPath, profileFlag string
+	root.PersistentFlags().StringVar(&configPath, "config", "",
+		"config file (default $GNAV_CONFIG, else $XDG_CONFIG_HOME/gnav/workspaces.yaml)")
+	root.PersistentFlags().StringVar(&profileFlag, "profile", "",
+		"profile to use instead of the one saved by 'gnav profile use'")
+	var checkCmd *cobra.Command
+	root.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		if outputFormat != "text" && outputFormat != "json" {
+			return fmt.Errorf("unknown output format %q (supported: text, json)", outputFormat)
+		}
+		switch {
+		case configPath != "" && profileFlag != "":
+			return errors.New("--config and --profile are mutually exclusive")
+		case configPath != "":
+			configFile = configPath
+			configPinned = true
+		case profileFlag != "":
+			if err := selectProfile(profileFlag); err != nil {
+				return err
+			}
+			configPinned = true
+		case os.Getenv("GNAV_CONFIG") != "":
+			configPinned = true
+		default:
+			if err := selectProfile(savedProfile()); err != nil {
+				return err
+			}
+		}
+		setup()
+		if configErr != nil && cmd != checkCmd {
+			fmt.Fprintf(os.Stderr, "gnav: %v\n", configErr)
+		}
+		return nil
+	}
+
+	var listJSON bool
+	var listFormat string
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Display workspace names",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			tmpl, err := parseFormat(listFormat)
+			if err != nil {
+				return err
+			}
+			entries, err := fetchEntries()
+			if err != nil {
+				return err
+			}
+			if jsonOutput(listJSON) && tmpl == nil {
+				return json.NewEncoder(os.Stdout).Encode(entries)
+			}
+			for _, e := range entries {
+				line, err := entryLine(e, tmpl)
+				if err != nil {
+					return err
+				}
+				fmt.Println(line)
+			}
+			return nil
+		},
+	}
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "print the workspaces as a JSON array")
+	listCmd.Flags().StringVar(&listFormat, "format", "",
+		"Go template for each line, e.g. '{{.Index}}:{{.Name}}{{if .Active}}*{{end}}'")
+	root.AddCommand(listCmd)
+
+	var currentFormat string
+	var currentNameOnly, currentIndexOnly bool
+	currentCmd := &cobra.Command{
+		Use:   "current",
+		Short: "Print the active workspace",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			switch {
+			case currentNameOnly && currentIndexOnly:
+				return errors.New("--name-only and --index-only are mutually exclusive")
+			case (currentNameOnly || currentIndexOnly) && currentFormat != "":
+				return errors.New("--format can't be combined with --name-only or --index-only")
+			case currentNameOnly:
+				currentFormat = "{{.Name}}"
+			case currentIndexOnly:
+				currentFormat = "{{.Label}}"
+			}
+			tmpl, err := parseFormat(currentFormat)
+			if err != nil {
+				return err
+			}
+			entries, err := fetchEntries()
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if !e.Active {
+					continue
+				}
+				if jsonOutput(false) && tmpl == nil {
+					return json.NewEncoder(os.Stdout).Encode(e)
+				}
+				line, err := entryLine(e, tmpl)
+				if err != nil {
+					return err
+				}
+				fmt.Println(line)
+				return nil
+			}
+			return errors.New("no active workspace found")
+		},
+	}
+	currentCmd.Flags().StringVar(&currentFormat, "format", "",
+		"Go template for the output, as for list")
+	currentCmd.Flags().BoolVar(&currentNameOnly, "name-only", false, "print only the name")
+	currentCmd.Flags().BoolVar(&currentIndexOnly, "index-only", false, "print only the index")
+	root.AddCommand(currentCmd)
+
+	// addSkipEmptyFlag adds --skip-empty to next or prev; given bare, it
+	// stays put when no other workspace has windows.
+	addSkipEmptyFlag := func(cmd *cobra.Command, p *string) {
+		cmd.Flags().StringVar(p, "skip-empty", "",
+			"step over workspaces without windows; when none other has any, "+skipEmptyStay+" stays put and "+skipEmptyNext+" steps anyway")
+		cmd.Flags().Lookup("skip-empty").NoOptDefVal = skipEmptyStay
+	}
+
+	var nextWrap, nextNoWrap, nextScreen bool
+	var nextSkipEmpty string
+	nextCmd := &cobra.Command{
+		Use:   "next",
+		Short: "Switch to the next workspace",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return stepWorkspace(1, nextWrap && !nextNoWrap, nextSkipEmpty, nextScreen)
+		},
+	}
+	nextCmd.Flags().BoolVar(&nextWrap, "wrap", true, "wrap to the first workspace after the last")
+	nextCmd.Flags().BoolVar(&nextNoWrap, "no-wrap", false,
+		"do nothing on the last workspace instead of wrapping to the first")
+	addSkipEmptyFlag(nextCmd, &nextSkipEmpty)
+	nextCmd.Flags().BoolVar(&nextScreen, "screen-relative", false,
+		"step only through workspaces on the focused output (i3, sway and Hyprland)")
+	nextCmd.MarkFlagsMutuallyExclusive("wrap", "no-wrap")
+	root.AddCommand(nextCmd)
+
+	var prevWrap, prevNoWrap, prevScreen bool
+	var prevSkipEmpty string
+	prevCmd := &cobra.Command{
+		Use:   "prev",
+		Short: "Switch to the previous workspace",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return stepWorkspace(-1, prevWrap && !prevNoWrap, prevSkipEmpty, prevScreen)
+		},
+	}
+	prevCmd.Flags().BoolVar(&prevWrap, "wrap", true, "wrap to the last workspace before the first")
+	prevCmd.Flags().BoolVar(&prevNoWrap, "no-wrap", false,
+		"do nothing on the first workspace instead of wrapping to the last")
+	addSkipEmptyFlag(prevCmd, &prevSkipEmpty)
+	prevCmd.Flags().BoolVar(&prevScreen, "screen-relative", false,
+		"step only through workspaces on the focused output (i3, sway and Hyprland)")
+	prevCmd.MarkFlagsMutuallyExclusive("wrap", "no-wrap")
+	root.AddCommand(prevCmd)
+
+	var moveWrap, moveFollow bool
+	moveWindowCmd := &cobra.Command{
+		Use:     "move-window <index|name|next|prev>",
+		Aliases: []string{"move"},
+		Short:   "Move the focused window to another workspace",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sendActiveWindow(args[0], moveWrap, moveFollow)
+		},
+	}
+	moveWindowCmd.Flags().BoolVar(&moveWrap, "wrap", false,
+		"wrap around at the first and last workspace")
+	moveWindowCmd.Flags().BoolVar(&moveFollow, "follow", false,
+		"switch to the target workspace afterwards")
+	root.AddCommand(moveWindowCmd)
+
+	var sendWrap bool
+	sendCmd := &cobra.Command{
+		Use:   "send <index|name|next|prev>",
+		Short: "Move the focused window to a workspace and stay",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sendActiveWindow(args[0], sendWrap, false)
+		},
+	}
+	sendCmd.Flags().BoolVar(&sendWrap, "wrap", false,
+		"wrap around at the first and last workspace")
+	root.AddCommand(sendCmd)
+
+	var takeWrap bool
+	takeCmd := &cobra.Command{
+		Use:   "take <index|name|next|prev>",
+		Short: "Move the focused window to a workspace and follow it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sendActiveWindow(args[0], takeWrap, true)
+		},
+	}
+	takeCmd.Flags().BoolVar(&takeWrap, "wrap", false,
+		"wrap around at the first and last workspace")
+	root.AddCommand(takeCmd)
+
+	var renameCurrent, renameEdit, renameStdin bool
+	renameCmd := &cobra.Command{
+		Use:   "rename <index|--current> <newName>",
+		Short: "Rename a workspace, or all of them with --edit or --stdin",
+		RunE: func(_ *cobra.Command, args []string) error {
+			if renameEdit || renameStdin {
+				if renameEdit && renameStdin || renameCurrent || len(args) > 0 {
+					return errors.New("--edit and --stdin take no other arguments")
+				}
+				var names []string
+				if renameEdit {
+					var err error
+					if names, err = editNames(); err != nil {
+						return err
+					}
+				} else {
+					scanner := bufio.NewScanner(os.Stdin)
+					for scanner.Scan() {
+						names = append(names, scanner.Text())
+					}
+					if err := scanner.Err(); err != nil {
+						return err
+					}
+				}
+				changes, err := renameAll(names)
+				for _, c := range changes {
+					fmt.Println(c)
+				}
+				return err
+			}
+			if len(args) == 0 {
+				return errors.New("usage: gnav rename <index> <newName>, or gnav rename --current <newName>")
+			}
+			var idx int
+			if renameCurrent {
+				active, err := getActiveWorkspaceIndex()
+				if err != nil {
+					return err
+				}
+				idx = active + 1
+			} else {
+				if len(args) < 2 {
+					return errors.New("usage: gnav rename <index> <newName>, or gnav rename --current <newName>")
+				}
+				i, e := strconv.Atoi(args[0])
+				if e != nil {
+					return e
+				}
+				idx = fromDisplayIndex(i, indexBase())
+				args = args[1:]
+			}
+			newN := strings.Join(args, " ")
+			req := daemonRequest{Cmd: "rename", Args: []string{strconv.Itoa(idx), newN}}
+			if ok, err := callDaemon(req, nil); ok {
+				return err
+			}
+			return renameLocal(idx, newN)
+		},
+	}
+	renameCmd.Flags().BoolVar(&renameCurrent, "current", false, "rename the active workspace; no index is given")
+	renameCmd.Flags().BoolVar(&renameEdit, "edit", false, "edit all names in $EDITOR, one per line")
+	renameCmd.Flags().BoolVar(&renameStdin, "stdin", false, "read names from stdin, one per line; blank lines keep a name")
+	root.AddCommand(renameCmd)
+
+	var noteClear bool
+	noteCmd := &cobra.Command{
+		Use:   "note <index> [text]",
+		Short: "Show or set a workspace's note",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			i, e := strconv.Atoi(args[0])
+			if e != nil {
+				return e
+			}
+			idx := fromDisplayIndex(i, indexBase())
+			text := strings.TrimSpace(strings.Join(args[1:], " "))
+			switch {
+			case noteClear:
+				if text != "" {
+					return errors.New("--clear takes no text")
+				}
+				return setNote(idx, "")
+			case text != "":
+				return setNote(idx, text)
+			}
+			if note := workspaceMeta(idx - 1).Note; note != "" {
+				fmt.Println(note)
+			}
+			return nil
+		},
+	}
+	noteCmd.Flags().BoolVar(&noteClear, "clear", false, "remove the note")
+	root.AddCommand(noteCmd)
+
+	var deleteTo int
+	var deleteYes bool
+	deleteCmd := &cobra.Command{
+		Use:   "delete <index>",
+		Short: "Remove a workspace, moving its windows to another",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			i, e := strconv.Atoi(args[0])
+			if e != nil {
+				return e
+			}
+			idx := fromDisplayIndex(i, indexBase())
+			target := deleteTarget(idx)
+			if deleteTo != -1 {
+				target = fromDisplayIndex(deleteTo, indexBase())
+			}
+			targetName := nameForIndex(target - 1)
+			if !deleteYes {
+				if !term.IsTerminal(int(os.Stdin.Fd())) {
+					return errors.New("not asking for confirmation without a terminal; pass --yes to delete")
+				}
+				if !confirm(fmt.Sprintf("Delete workspace %s (%s)?", formatIndex(idx-1), nameForIndex(idx-1))) {
+					return errors.New("aborted")
+				}
+			}
+			moved, err := deleteWorkspace(idx, target)
+			if err != nil {
+				return err
+			}
+			if moved > 0 {
+				fmt.Printf("moved %d window(s) to %s\n", moved, targetName)
+			}
+			return nil
+		},
+	}
+	deleteCmd.Flags().IntVar(&deleteTo, "to", -1,
+		"workspace that gets the deleted one's windows (default: the one before it)")
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "skip the confirmation prompt")
+	root.AddCommand(deleteCmd)
+
+	var newName string
+	var newSwitch, newAfterCurrent bool
+	newCmd := &cobra.Command{
+		Use:   "new",
+		Short: "Add one workspace, optionally named and switched to",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			idx, err := newWorkspace(strings.TrimSpace(newName), newAfterCurrent)
+			if err != nil || !newSwitch {
+				return err
+			}
+			return switchWorkspace(idx)
+		},
+	}
+	newCmd.Flags().StringVar(&newName, "name", "", "name of the new workspace")
+	newCmd.Flags().BoolVar(&newSwitch, "switch", false, "switch to the new workspace")
+	newCmd.Flags().BoolVar(&newAfterCurrent, "after-current", false,
+		"insert it after the active workspace instead of at the end")
+	root.AddCommand(newCmd)
+
+	var setCountForce bool
+	setCountCmd := &cobra.Command{
+		Use:   "set-count <n>",
+		Short: "Set the exact number of workspaces, growing or shrinking",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return err
+			}
+			return setWorkspaceCount(n, setCountForce)
+		},
+	}
+	setCountCmd.Flags().BoolVar(&setCountForce, "force", false,
+		"move windows off the removed workspaces instead of refusing")
+	root.AddCommand(setCountCmd)
+
+	root.AddCommand(&cobra.Command{
+		Use:   "swap <index> <index>",
+		Short: "Exchange two workspaces, windows and names",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			var idx [2]int
+			for k, a := range args {
+				i, e := strconv.Atoi(a)
+				if e != nil {
+					return e
+				}
+				idx[k] = fromDisplayIndex(i, indexBase())
+			}
+			return swapWorkspaces(idx[0], idx[1])
+		},
+	})
+
+	root.AddCommand(&cobra.Command{
+		Use:   "insert <index> [name]",
+		Short: "Insert a workspace at a position, moving the ones after it up",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			i, e := strconv.Atoi(args[0])
+			if e != nil {
+				return e
+			}
+			name := strings.TrimSpace(strings.Join(args[1:], " "))
+			return insertWorkspace(fromDisplayIndex(i, indexBase()), name)
+		},
+	})
+
+	var autoNameAllFlag bool
+	autoNameCmd := &cobra.Command{
+		Use:     "auto-name",
+		Aliases: []string{"autoname"},
+		Short:   "Name the active workspace, or all unnamed ones, after their apps",
+		Args:    cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if autoNameAllFlag {
+				changes, err := autoNameAll()
+				for _, c := range changes {
+					fmt.Println(c)
+				}
+				return err
+			}
+			name, err := autoNameActive()
+			if err != nil {
+				return err
+			}
+			fmt.Println(name)
+			return nil
+		},
+	}
+	autoNameCmd.Flags().BoolVar(&autoNameAllFlag, "all", false,
+		"name every unnamed workspace after its dominant app")
+	root.AddCommand(autoNameCmd)
+
+	var rulesDryRun bool
+	applyRulesCmd := &cobra.Command{
+		Use:   "apply-rules",
+		Short: "Move open windows to the workspaces their rules name",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			moves, err := applyRules(rulesDryRun)
+			for _, m := range moves {
+				fmt.Printf("%s -> %s\n", m.Window.Title, nameForIndex(m.To-1))
+			}
+			return err
+		},
+	}
+	applyRulesCmd.Flags().BoolVarP(&rulesDryRun, "dry-run", "n", false, "print the moves without making them")
+	root.AddCommand(applyRulesCmd)
+
+	var statusJSON, statusI3bar, statusListen bool
+	var statusFormat string
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Summarize config against the system state",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if statusI3bar {
+				return runBar(statusListen, statusFormat, i3barModule)
+			}
+			if statusListen || statusFormat != "" {
+				return errors.New("--listen and --format require --i3bar")
+			}
+			r, err := collectStatus()
+			if err != nil {
+				return err
+			}
+			if jsonOutput(statusJSON) {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(r)
+			}
+			printStatus(r)
+			return nil
+		},
+	}
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "print the report as JSON")
+	statusCmd.Flags().BoolVar(&statusI3bar, "i3bar", false,
+		"print the active workspace as an i3bar block for i3blocks or i3status-rust")
+	statusCmd.Flags().BoolVar(&statusListen, "listen", false, "with --i3bar, print a block on every workspace change")
+	statusCmd.Flags().StringVar(&statusFormat, "format", "",
+		"with --i3bar, Go template for the block's text, as for list")
+	root.AddCommand(statusCmd)
+
+	var keysCheatsheet bool
+	keysCmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Show the TUI keybindings",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if jsonOutput(false) {
+				type keyBinding struct {
+					Key    string `json:"key"`
+					Action string `json:"action"`
+				}
+				keys := make([]keyBinding, 0, len(tuiKeys))
+				for _, k := range tuiKeys {
+					keys = append(keys, keyBinding{k.key, k.action})
+				}
+				return json.NewEncoder(os.Stdout).Encode(keys)
+			}
+			width := 0
+			if keysCheatsheet && term.IsTerminal(int(os.Stdout.Fd())) {
+				width, _, _ = term.GetSize(int(os.Stdout.Fd()))
+			}
+			fmt.Println(keyCheatsheet(width))
+			return nil
+		},
+	}
+	keysCmd.Flags().BoolVar(&keysCheatsheet, "cheatsheet", false,
+		"fit the table to the terminal width")
+	root.AddCommand(keysCmd)
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and maintain the config file",
+	}
+	var repairPrune bool
+	repairCmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Fill blank names and optionally prune extra entries",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			changes, err := repairConfig(repairPrune)
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				fmt.Println("nothing to repair")
+				return nil
+			}
+			for _, c := range changes {
+				fmt.Println(c)
+			}
+			return nil
+		},
+	}
+	repairCmd.Flags().BoolVar(&repairPrune, "prune", false,
+		"drop names beyond the system workspace count")
+	configCmd.AddCommand(repairCmd)
+	checkCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Validate the config file and list its problems by line",
+		Args:  cobra.NoArgs,
+		// Problems are listed already; usage would bury them.
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			b, err := ioutil.ReadFile(configFile)
+			if err != nil {
+				return err
+			}
+			problems := checkConfigData(configFile, b)
+			if len(problems) == 0 {
+				fmt.Printf("%s: ok\n", configFile)
+				return nil
+			}
+			for _, p := range problems {
+				fmt.Println(p)
+			}
+			return fmt.Errorf("%d problem(s) in %s", len(problems), configFile)
+		},
+	}
+	configCmd.AddCommand(checkCmd)
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "path",
+		Short: "Print the path of the config file in use",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			fmt.Println(configFile)
+			return nil
+		},
+	})
+	root.AddCommand(configCmd)
+
+	root.AddCommand(&cobra.Command{
+		Use:   "export",
+		Short: "Print the workspace setup (count, dynamic flag, names, metadata) as YAML",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			l, err := exportLayout()
+			if err != nil {
+				return err
+			}
+			format := "yaml"
+			if jsonOutput(false) {
+				format = "json"
+			}
+			b, err := encodeFormat(format, l)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(b)
+			return err
+		},
+	})
+	root.AddCommand(&cobra.Command{
+		Use:   "restore",
+		Short: "Apply the configured workspace count, dynamic mode and names",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			changes, err := restoreWorkspaces()
+			for _, c := range changes {
+				fmt.Println(c)
+			}
+			if err == nil && len(changes) == 0 {
+				fmt.Println("nothing to restore")
+			}
+			return err
+		},
+	})
+	var serviceEnable, servicePrint bool
+	serviceCmd := &cobra.Command{
+		Use:   "install-service",
+		Short: "Install a systemd user unit that runs restore at session start",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			var args []string
+			switch {
+			case configPath != "":
+				abs, err := filepath.Abs(configPath)
+				if err != nil {
+					return err
+				}
+				args = []string{"--config", abs}
+			case profileFlag != "":
+				args = []string{"--profile", profileFlag}
+			}
+			if servicePrint {
+				exe, err := os.Executable()
+				if err != nil {
+					return err
+				}
+				fmt.Print(restoreUnit(exe, args))
+				return nil
+			}
+			path, err := installRestoreService(args, serviceEnable)
+			if err != nil {
+				return err
+			}
+			fmt.Println("wrote", path)
+			if !serviceEnable {
+				fmt.Println("enable it with: systemctl --user enable " + restoreUnitName)
+			}
+			return nil
+		},
+	}
+	serviceCmd.Flags().BoolVar(&serviceEnable, "enable", false, "also enable the unit with systemctl --user")
+	serviceCmd.Flags().BoolVar(&servicePrint, "print", false, "print the unit instead of installing it")
+	root.AddCommand(serviceCmd)
+
+	shellExtCmd := &cobra.Command{
+		Use:   "shell-extension",
+		Short: "Manage the GNOME Shell extension gnav needs on Wayland",
+	}
+	shellExtCmd.AddCommand(&cobra.Command{
+		Use:   "install",
+		Short: "Install and enable the GNOME Shell extension",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			dir, enabled, err := installShellExtension()
+			if err != nil {
+				return err
+			}
+			fmt.Println("wrote", dir)
+			if !enabled {
+				fmt.Println("log out and back in, then run: gnome-extensions enable " + shellExtUUID)
+			}
+			return nil
+		},
+	})
+	root.AddCommand(shellExtCmd)
 
-	var cur string
-	if idx-1 < len(cfg.Names) {
-		cur = cfg.Names[idx-1]
-	} else {
-		cur = fmt.Sprintf("Workspace %d", idx)
+	var importYes bool
+	importCmd := &cobra.Command{
+		Use:   "import <file|->",
+		Short: "Apply a workspace setup written by export",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			l, err := readLayout(args[0])
+			if err != nil {
+				return err
+			}
+			if !importYes {
+				if !confirm(fmt.Sprintf("Replace it with %d workspace names from %s?", len(l.Workspaces), args[0])) {
+					return errors.New("aborted")
+				}
+			}
+			return importLayout(l)
+		},
 	}
+	importCmd.Flags().BoolVarP(&importYes, "yes", "y", false, "skip the confirmation prompt")
+	root.AddCommand(importCmd)
 
-	form.AddInputField("Name", cur, 20, nil, nil)
-	form.AddButton("OK", func() {
-		newN := form.GetFormItemByLabel("Name").(*tview.InputField).GetText()
-		if newN != "" {
-			_ = renameLocal(idx, newN)
-			refresh()
-		}
-		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "List and switch between config profiles",
+	}
+	profileCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the profiles, marking the one in use",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			names, err := profileNames()
+			if err != nil {
+				return err
+			}
+			for _, n := range names {
+				mark := "  "
+				if n == profile || (n == defaultProfile && profile == "") {
+					mark = "* "
+				}
+				fmt.Println(mark + n)
+			}
+			return nil
+		},
 	})
-	form.AddButton("Cancel", func() {
-		tui.app.SetRoot(tui.layout, true).SetFocus(tui.list)
+	profileCmd.AddCommand(&cobra.Command{
+		Use:   "use <name>",
+		Short: "Make a profile the default for later invocations",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return useProfile(args[0])
+		},
 	})
-	tui.app.SetRoot(form, true).SetFocus(form)
-}
-
-// -----------------------------------------------------------------------------
-// Main + cobra
-// -----------------------------------------------------------------------------
+	root.AddCommand(profileCmd)
 
-func main() {
-	_ = loadConfig()
+	var runOn string
+	var runSwitch bool
+	var runTimeout time.Duration
+	runCmd := &cobra.Command{
+		Use:   "run [--on <workspace>] [--switch] -- <command> [args...]",
+		Short: "Start a program and move its window to a workspace",
+		Long: "Start a program and move its first window to a workspace, by default\n" +
+			"the one active when it was started. A single argument is run as a shell\n" +
+			"command line; several are run as the program and its arguments.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			var idx int
+			if runOn == "" {
+				active, err := getActiveWorkspaceIndex()
+				if err != nil {
+					return err
+				}
+				idx = active + 1
+			} else {
+				sc, err := getSystemWorkspaceCount()
+				if err != nil {
+					return err
+				}
+				var ok bool
+				if idx, ok, err = resolveWorkspace(runOn, false); err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("no %s workspace", strings.ToLower(runOn))
+				}
+				if idx < 1 || idx > sc {
+					return fmt.Errorf("invalid workspace index: %s", runOn)
+				}
+			}
+			command := args[0]
+			if len(args) > 1 {
+				command = shellJoin(args)
+			}
+			w, err := launchOn(command, idx, runTimeout)
+			if err != nil {
+				return err
+			}
+			if runSwitch {
+				return raiseWindow(w)
+			}
+			return nil
+		},
+	}
+	runCmd.Flags().StringVar(&runOn, "on", "", "workspace to put the window on (index, name, next or prev)")
+	runCmd.Flags().BoolVar(&runSwitch, "switch", false, "switch to the workspace and focus the window")
+	runCmd.Flags().DurationVar(&runTimeout, "timeout", launchTimeout, "how long to wait for the window")
+	root.AddCommand(runCmd)
 
-	root := &cobra.Command{
-		Use: "gnav",
+	var statsWeek bool
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the time spent on each workspace today, or per day this week",
+		Args:  cobra.NoArgs,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			return runTUI()
+			entries, err := readSwitchLog()
+			if err != nil {
+				return err
+			}
+			days := 1
+			if statsWeek {
+				days = 7
+			}
+			stats := switchStats(entries, time.Now(), days)
+			if jsonOutput(false) {
+				return json.NewEncoder(os.Stdout).Encode(struct {
+					Days  []dayStats      `json:"days"`
+					Total []workspaceTime `json:"total"`
+				}{stats, sumStats(stats)})
+			}
+			for _, d := range stats {
+				fmt.Println(d.Date)
+				printWorkspaceTimes(d.Workspaces)
+			}
+			if statsWeek {
+				fmt.Println("Total")
+				printWorkspaceTimes(sumStats(stats))
+			}
+			return nil
 		},
 	}
+	statsCmd.Flags().BoolVar(&statsWeek, "week", false, "show the last seven days and their total")
+	root.AddCommand(statsCmd)
 
-	root.AddCommand(&cobra.Command{
+	var launchAll bool
+	launchCmd := &cobra.Command{
+		Use:   "launch [workspace]",
+		Short: "Start a workspace's autostart commands on it",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			report := func(idx int) func(string, error) {
+				return func(cmd string, err error) {
+					if err != nil {
+						fmt.Fprintln(os.Stderr, "gnav:", err)
+						return
+					}
+					fmt.Printf("%s -> %s\n", cmd, nameForIndex(idx-1))
+				}
+			}
+			if launchAll {
+				if len(args) > 0 {
+					return errors.New("--all takes no workspace")
+				}
+				var firstErr error
+				for i := range cfg.Workspaces {
+					if len(cfg.Workspaces[i].Autostart) == 0 {
+						continue
+					}
+					if err := launchAutostart(i+1, report(i+1)); err != nil && firstErr == nil {
+						firstErr = err
+					}
+				}
+				return firstErr
+			}
+			if len(args) == 0 {
+				return errors.New("expected a workspace, or --all")
+			}
+			idx, _, err := resolveWorkspace(args[0], false)
+			if err != nil {
+				return err
+			}
+			return launchAutostart(idx, report(idx))
+		},
+	}
+	launchCmd.Flags().BoolVar(&launchAll, "all", false, "start the autostart commands of every workspace")
+	root.AddCommand(launchCmd)
+
+	templateCmd := &cobra.Command{
+		Use:   "template",
+		Short: "Save and recreate work sessions: workspace names and their apps",
+	}
+	templateCmd.AddCommand(&cobra.Command{
 		Use:   "list",
-		Short: "Display workspace names",
+		Short: "List the saved templates",
+		Args:  cobra.NoArgs,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			sc, _ := getSystemWorkspaceCount()
-			for i := 0; i < sc; i++ {
-				var n string
-				if i < len(cfg.Names) {
-					n = cfg.Names[i]
-				} else {
-					n = fmt.Sprintf("Workspace %d", i+1)
-				}
-				fmt.Printf("[%d] %s\n", i+1, n)
+			names, err := configFileNames(templateDir())
+			if err != nil {
+				return err
+			}
+			for _, n := range names {
+				fmt.Println(n)
 			}
 			return nil
 		},
 	})
-
-	root.AddCommand(&cobra.Command{
-		Use:   "rename <index> <newName>",
-		Short: "Rename a workspace",
-		Args:  cobra.MinimumNArgs(2),
+	var templateForce bool
+	templateSaveCmd := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Save the workspaces and the apps open on them as a template",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
-			i, e := strconv.Atoi(args[0])
-			if e != nil {
-				return e
+			path, err := saveTemplate(args[0], templateForce)
+			if err != nil {
+				return err
 			}
-			newN := strings.Join(args[1:], " ")
-			return renameLocal(i, newN)
+			fmt.Printf("saved %s (launch commands are guessed from window classes; edit them as needed)\n", path)
+			return nil
 		},
-	})
+	}
+	templateSaveCmd.Flags().BoolVarP(&templateForce, "force", "f", false, "replace an existing template")
+	templateCmd.AddCommand(templateSaveCmd)
+	var templateNoLaunch bool
+	templateApplyCmd := &cobra.Command{
+		Use:   "apply <name>",
+		Short: "Name the workspaces after a template and launch its apps on them",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			t, err := loadTemplate(args[0])
+			if err != nil {
+				return err
+			}
+			return applyTemplate(t, !templateNoLaunch, func(cmd string, idx int, err error) {
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "gnav:", err)
+					return
+				}
+				fmt.Printf("%s -> %s\n", cmd, nameForIndex(idx-1))
+			})
+		},
+	}
+	templateApplyCmd.Flags().BoolVar(&templateNoLaunch, "no-launch", false, "only set up the workspaces")
+	templateCmd.AddCommand(templateApplyCmd)
+	root.AddCommand(templateCmd)
+
+	var syncPush, syncPull bool
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync names with GNOME's workspace-names setting",
+		Long: "Sync names with GNOME's workspace-names setting. Without flags the two\n" +
+			"lists are merged; conflicts go to the side named by name_sync_prefer.",
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			mode := nameSyncMerge
+			switch {
+			case syncPush && syncPull:
+				return errors.New("--push and --pull are mutually exclusive")
+			case syncPush:
+				mode = nameSyncPush
+			case syncPull:
+				mode = nameSyncPull
+			}
+			changes, err := syncNames(mode)
+			if err != nil {
+				return err
+			}
+			for _, c := range changes {
+				fmt.Println(c)
+			}
+			return nil
+		},
+	}
+	syncCmd.Flags().BoolVar(&syncPush, "push", false, "overwrite GNOME's names with gnav's")
+	syncCmd.Flags().BoolVar(&syncPull, "pull", false, "overwrite gnav's names with GNOME's")
+	root.AddCommand(syncCmd)
 
 	root.AddCommand(&cobra.Command{
+		Use:   "daemon",
+		Short: "Serve workspace change events on a unix socket",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runDaemon()
+		},
+	})
+
+	var watchJSON bool
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print a line whenever the active workspace or count changes",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runWatch(jsonOutput(watchJSON))
+		},
+	}
+	watchCmd.Flags().BoolVar(&watchJSON, "json", false, "print each change as a JSON object")
+	root.AddCommand(watchCmd)
+
+	var createStatic bool
+	createCmd := &cobra.Command{
 		Use:   "create <num>",
 		Short: "Add or expand static workspaces",
 		Args:  cobra.ExactArgs(1),
@@ -631,46 +3361,227 @@ func main() {
 			if e != nil {
 				return e
 			}
-			return createWorkspaces(x)
+			err := createWorkspaces(x, createStatic)
+			if errors.Is(err, errDynamicCreate) {
+				fmt.Fprintln(os.Stderr, "gnav: dynamic workspaces are on, so GNOME adds workspaces as they are used;\n"+
+					"switch to the last one for a new workspace, or pass --static to fix the count")
+				return nil
+			}
+			return err
 		},
-	})
+	}
+	createCmd.Flags().BoolVar(&createStatic, "static", false,
+		"turn dynamic workspaces off if they are on, until the extra workspaces are removed")
+	root.AddCommand(createCmd)
 
 	root.AddCommand(&cobra.Command{
-		Use:   "switch <index>",
-		Short: "Switch to workspace by index",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(_ *cobra.Command, args []string) error {
-			i, e := strconv.Atoi(args[0])
-			if e != nil {
-				return e
+		Use:   "back",
+		Short: "Switch to the previously active workspace",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			idx, err := recentWorkspace(1)
+			if err != nil {
+				return err
+			}
+			if ok, err := callDaemon(daemonRequest{Cmd: "switch", Args: []string{strconv.Itoa(idx)}}, nil); ok {
+				return err
 			}
-			return switchWorkspace(i)
+			return switchWorkspace(idx)
 		},
 	})
 
-	root.AddCommand(&cobra.Command{
-		Use:   "dynamic <on|off>",
+	var windowsJSON bool
+	windowsCmd := &cobra.Command{
+		Use:   "windows",
+		Short: "List windows grouped by workspace",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			wins, err := listWindows()
+			if err != nil {
+				return err
+			}
+			if jsonOutput(windowsJSON) {
+				entries := make([]windowEntry, 0, len(wins))
+				for _, w := range wins {
+					entries = append(entries, windowEntry{w.ID, w.Desktop + 1, w.Class, w.Title})
+				}
+				return json.NewEncoder(os.Stdout).Encode(entries)
+			}
+			sc, err := getSystemWorkspaceCount()
+			if err != nil {
+				return err
+			}
+			printWindows(wins, sc)
+			return nil
+		},
+	}
+	windowsCmd.Flags().BoolVar(&windowsJSON, "json", false, "print the windows as a JSON array")
+	root.AddCommand(windowsCmd)
+
+	var findFirst bool
+	findCmd := &cobra.Command{
+		Use:   "find <query>",
+		Short: "Switch to and focus the window whose title or class matches",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return findAndFocus(strings.Join(args, " "), findFirst)
+		},
+	}
+	findCmd.Flags().BoolVar(&findFirst, "first", false, "take the first match instead of failing when several match")
+	root.AddCommand(findCmd)
+
+	queryCmd := &cobra.Command{
+		Use:   "query [text]",
+		Short: "Print the workspaces matching text as ranked JSON, for launcher extensions",
+		RunE: func(_ *cobra.Command, args []string) error {
+			matches, err := queryWorkspaces(strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(os.Stdout).Encode(matches)
+		},
+	}
+	root.AddCommand(queryCmd)
+
+	var mruList bool
+	mruCmd := &cobra.Command{
+		Use:   "mru [n]",
+		Short: "Switch to the nth most recently used workspace (default 1)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if mruList {
+				entries, err := fetchEntries()
+				if err != nil {
+					return err
+				}
+				var recent []listEntry
+				for _, i := range mruOrder(loadHistory(), len(entries)) {
+					recent = append(recent, entries[i])
+				}
+				if jsonOutput(false) {
+					return json.NewEncoder(os.Stdout).Encode(recent)
+				}
+				for _, e := range recent {
+					line, _ := entryLine(e, nil)
+					fmt.Println(line)
+				}
+				return nil
+			}
+			n := 1
+			if len(args) == 1 {
+				var err error
+				if n, err = strconv.Atoi(args[0]); err != nil {
+					return err
+				}
+			}
+			idx, err := recentWorkspace(n)
+			if err != nil {
+				return err
+			}
+			if ok, err := callDaemon(daemonRequest{Cmd: "switch", Args: []string{strconv.Itoa(idx)}}, nil); ok {
+				return err
+			}
+			return switchWorkspace(idx)
+		},
+	}
+	mruCmd.Flags().BoolVar(&mruList, "list", false, "print the workspaces by recency instead of switching")
+	root.AddCommand(mruCmd)
+
+	var switchIndexBase int
+	var switchExact bool
+	switchCmd := &cobra.Command{
+		Use:   "switch <index|name>",
+		Short: "Switch to workspace by index or name",
+		Long: "Switch to workspace by index or name. Names match case-insensitively\n" +
+			"by prefix, substring or letters in order unless --exact is given.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var idx int
+			if i, e := strconv.Atoi(args[0]); e == nil {
+				base := indexBase()
+				if cmd.Flags().Changed("index-base") {
+					if switchIndexBase != 0 && switchIndexBase != 1 {
+						return errors.New("--index-base must be 0 or 1")
+					}
+					base = switchIndexBase
+				}
+				idx = fromDisplayIndex(i, base)
+			} else {
+				n, err := workspaceByName(args[0], switchExact)
+				if err != nil {
+					return err
+				}
+				idx = n
+			}
+			if ok, err := callDaemon(daemonRequest{Cmd: "switch", Args: []string{strconv.Itoa(idx)}}, nil); ok {
+				return err
+			}
+			return switchWorkspace(idx)
+		},
+	}
+	switchCmd.Flags().BoolVar(&switchExact, "exact", false, "only switch to a workspace with exactly this name")
+	switchCmd.Flags().IntVar(&switchIndexBase, "index-base", 1,
+		"number of the first workspace, 0 or 1 (default from config index_base)")
+	root.AddCommand(switchCmd)
+
+	var dynamicYes bool
+	dynamicCmd := &cobra.Command{
+		Use:   "dynamic <on|off|toggle>",
 		Short: "Enable/disable GNOME dynamic workspaces",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
-			switch strings.ToLower(args[0]) {
+			mode := strings.ToLower(args[0])
+			var on bool
+			switch mode {
 			case "on":
-				return setDynamic(true)
+				on = true
 			case "off":
-				return setDynamic(false)
+				on = false
+			case "toggle":
+				cur, err := getDynamic()
+				if err != nil {
+					return err
+				}
+				on = !cur
 			default:
-				return errors.New("usage: gnav dynamic on|off")
+				return errors.New("usage: gnav dynamic on|off|toggle")
 			}
+			if mode != "on" && !dynamicYes {
+				verb := "off"
+				if on {
+					verb = "on"
+				}
+				if !confirm(fmt.Sprintf("Turn dynamic workspaces %s?", verb)) {
+					return errors.New("aborted")
+				}
+			}
+			return setDynamic(on)
 		},
-	})
+	}
+	dynamicCmd.Flags().BoolVarP(&dynamicYes, "yes", "y", false, "skip the confirmation prompt")
+	dynamicCmd.Flags().BoolVar(&dynamicYes, "force", false, "same as --yes")
+	root.AddCommand(dynamicCmd)
 
-	root.AddCommand(&cobra.Command{
+	addMenuOrderFlag := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&menuOrderFlag, "order", "",
+			"workspace order: "+strings.Join(menuOrders, ", ")+" (default menu_order, then index)")
+	}
+
+	var wofiPlain bool
+	wofiCmd := &cobra.Command{
 		Use:   "wofi",
 		Short: "Output workspace list for wofi",
 		RunE: func(_ *cobra.Command, _ []string) error {
-			return wofiIntegration()
+			if err := checkMenuOrderFlag(); err != nil {
+				return err
+			}
+			return wofiIntegration(wofiPlain)
 		},
-	})
+	}
+	wofiCmd.Flags().BoolVar(&wofiPlain, "plain", false,
+		"print the list without Pango markup, marking the active row with a prefix")
+	addMenuOrderFlag(wofiCmd)
+	root.AddCommand(wofiCmd)
 
 	root.AddCommand(&cobra.Command{
 		Use:   "wofi-switch",
@@ -680,10 +3591,14 @@ func main() {
 		},
 	})
 
-	root.AddCommand(&cobra.Command{
-		Use:   "wofi-run",
-		Short: "Interactive workspace selection with wofi",
-		RunE: func(_ *cobra.Command, _ []string) error {
+	wofiRunCmd := &cobra.Command{
+		Use:     "wofi-run [-- launcher args...]",
+		Short:   "Interactive workspace selection with wofi",
+		Example: `  gnav wofi-run -- --prompt Workspace --style ~/.config/wofi/ws.css`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := checkMenuOrderFlag(); err != nil {
+				return err
+			}
 			lock := flock.New("/tmp/gnav-wofi-run.lock")
 			locked, err := lock.TryLock()
 			if err != nil {
@@ -694,17 +3609,129 @@ func main() {
 			}
 			defer lock.Close()
 
-			return wofiRun()
+			return runMenu("", args)
+		},
+	}
+	addMenuOrderFlag(wofiRunCmd)
+	root.AddCommand(wofiRunCmd)
+
+	root.AddCommand(&cobra.Command{
+		Use:     "wofi-rename [-- launcher args...]",
+		Short:   "Rename the active workspace, asking for the name with wofi",
+		Example: `  gnav wofi-rename -- --style ~/.config/wofi/ws.css`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return renameCurrentAction(menuAsker("", args))
 		},
 	})
 
+	fzfCmd := &cobra.Command{
+		Use:   "fzf",
+		Short: "Pick a workspace with fzf in the terminal",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if err := checkMenuOrderFlag(); err != nil {
+				return err
+			}
+			return runMenu("fzf", nil)
+		},
+	}
+	addMenuOrderFlag(fzfCmd)
+	root.AddCommand(fzfCmd)
+
+	var waybarListen bool
+	var waybarFormat string
+	waybarCmd := &cobra.Command{
+		Use:   "waybar",
+		Short: "Print a waybar custom module update",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runBar(waybarListen, waybarFormat, waybarModule)
+		},
+	}
+	waybarCmd.Flags().BoolVar(&waybarListen, "listen", false, "print an update on every workspace change")
+	waybarCmd.Flags().StringVar(&waybarFormat, "format", "",
+		"Go template for each workspace's text, as for list")
+	root.AddCommand(waybarCmd)
+
 	root.AddCommand(&cobra.Command{
+		Use:   "waybar-click <left|right|scroll-up|scroll-down>",
+		Short: "Handle a click or scroll on the waybar module",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return waybarClick(args[0])
+		},
+	})
+
+	var ewwListen bool
+	ewwCmd := &cobra.Command{
+		Use:   "eww",
+		Short: "Print the workspaces as JSON for an eww widget",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runBar(ewwListen, "", ewwModule)
+		},
+	}
+	ewwCmd.Flags().BoolVar(&ewwListen, "listen", false, "print a line on every workspace change")
+	root.AddCommand(ewwCmd)
+
+	var polybarListen bool
+	var polybarFormat string
+	polybarCmd := &cobra.Command{
+		Use:   "polybar",
+		Short: "Print a polybar module line with click-to-switch actions",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return runBar(polybarListen, polybarFormat, polybarModule)
+		},
+	}
+	polybarCmd.Flags().BoolVar(&polybarListen, "listen", false, "print a line on every workspace change")
+	polybarCmd.Flags().StringVar(&polybarFormat, "format", "",
+		"Go template for each workspace's label, as for list")
+	root.AddCommand(polybarCmd)
+
+	var menuLauncher string
+	menuCmd := &cobra.Command{
+		Use:   "menu [-- launcher args...]",
+		Short: "Pick a workspace with a dmenu-style launcher",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkMenuOrderFlag(); err != nil {
+				return err
+			}
+			name := ""
+			if cmd.Flags().Changed("launcher") {
+				if _, ok := launchers[menuLauncher]; !ok {
+					return unknownLauncherError(menuLauncher)
+				}
+				name = menuLauncher
+			}
+			lock := flock.New("/tmp/gnav-wofi-run.lock")
+			locked, err := lock.TryLock()
+			if err != nil {
+				return err
+			}
+			if !locked {
+				return nil
+			}
+			defer lock.Close()
+
+			return runMenu(name, args)
+		},
+	}
+	menuCmd.Flags().StringVar(&menuLauncher, "launcher", "wofi",
+		"menu program: "+strings.Join(launcherNames, ", ")+" (default menu_command, then wofi)")
+	addMenuOrderFlag(menuCmd)
+	root.AddCommand(menuCmd)
+
+	interactiveCmd := &cobra.Command{
 		Use:   "interactive",
 		Short: "Launch text-based UI",
 		RunE: func(_ *cobra.Command, _ []string) error {
-			return runTUI()
+			return runTUI(switchAndQuit)
 		},
-	})
+	}
+	interactiveCmd.Flags().BoolVar(&switchAndQuit, "switch-and-quit", false,
+		"exit the TUI after switching workspace")
+	root.AddCommand(interactiveCmd)
 
 	if err := root.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setConfig makes c the loaded config for the rest of the test.
+func setConfig(t *testing.T, c *Config) {
+	t.Helper()
+	old := cfg
+	cfg = c
+	t.Cleanup(func() { cfg = old })
+}
+
+func TestNameForIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		i    int
+		want string
+	}{
+		{"configured", Config{Workspaces: []WorkspaceMeta{{Name: "Web"}, {Name: "Code"}}}, 1, "Code"},
+		{"past the configured ones", Config{Workspaces: []WorkspaceMeta{{Name: "Web"}}}, 2, "Workspace 3"},
+		{"empty config", Config{}, 0, "Workspace 1"},
+		{"negative", Config{Workspaces: []WorkspaceMeta{{Name: "Web"}}}, -1, "Workspace 0"},
+		{"template", Config{DefaultNameTemplate: "Desk {n}"}, 4, "Desk 5"},
+		{"template without {n}", Config{DefaultNameTemplate: "Spare"}, 4, "Spare"},
+		{"configured empty name", Config{Workspaces: []WorkspaceMeta{{Name: ""}}}, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cfg
+			setConfig(t, &c)
+			if got := nameForIndex(tt.i); got != tt.want {
+				t.Errorf("nameForIndex(%d) = %q, want %q", tt.i, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	named := []WorkspaceMeta{{Name: "Web"}, {Name: "Code"}, {Name: "Chat"}}
+	tests := []struct {
+		name  string
+		cfg   Config
+		i     int
+		count int
+		dyn   bool
+		want  string
+	}{
+		{"static", Config{Workspaces: named}, 2, 3, false, "Chat"},
+		{"dynamic trailing slot", Config{Workspaces: named}, 2, 3, true, "New Workspace"},
+		{"dynamic other slot", Config{Workspaces: named}, 1, 3, true, "Code"},
+		{"custom label", Config{Workspaces: named, NewWorkspaceLabel: "+"}, 2, 3, true, "+"},
+		{"label only when dynamic", Config{Workspaces: named, NewWorkspaceLabel: "+"}, 2, 3, false, "Chat"},
+		{"unnamed", Config{}, 1, 3, true, "Workspace 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cfg
+			setConfig(t, &c)
+			if got := displayName(tt.i, tt.count, tt.dyn); got != tt.want {
+				t.Errorf("displayName(%d, %d, %v) = %q, want %q", tt.i, tt.count, tt.dyn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRomanRoundTrip(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{1, "I"}, {4, "IV"}, {9, "IX"}, {14, "XIV"}, {40, "XL"},
+		{90, "XC"}, {400, "CD"}, {1994, "MCMXCIV"}, {3999, "MMMCMXCIX"},
+	}
+	for _, tt := range tests {
+		got := toRoman(tt.n)
+		if got != tt.want {
+			t.Errorf("toRoman(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+		if n, ok := fromRoman(got); !ok || n != tt.n {
+			t.Errorf("fromRoman(%q) = %d, %v, want %d, true", got, n, ok, tt.n)
+		}
+	}
+	for n := 1; n <= 3999; n++ {
+		if m, ok := fromRoman(toRoman(n)); !ok || m != n {
+			t.Fatalf("fromRoman(toRoman(%d)) = %d, %v", n, m, ok)
+		}
+	}
+	for _, n := range []int{0, -1, 4000} {
+		if got := toRoman(n); got != "" {
+			t.Errorf("toRoman(%d) = %q, want \"\"", n, got)
+		}
+	}
+	if n, ok := fromRoman("xiv"); !ok || n != 14 {
+		t.Errorf("fromRoman(\"xiv\") = %d, %v, want 14, true", n, ok)
+	}
+	for _, s := range []string{"", "IIII", "VX", "IC", "MMMM", "X1", "abc"} {
+		if n, ok := fromRoman(s); ok {
+			t.Errorf("fromRoman(%q) = %d, true, want false", s, n)
+		}
+	}
+}
+
+func TestLettersRoundTrip(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, ""}, {1, "a"}, {2, "b"}, {26, "z"}, {27, "aa"},
+		{52, "az"}, {53, "ba"}, {702, "zz"}, {703, "aaa"},
+	}
+	for _, tt := range tests {
+		got := toLetters(tt.n)
+		if got != tt.want {
+			t.Errorf("toLetters(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+		if tt.n == 0 {
+			continue
+		}
+		if n, ok := fromLetters(got); !ok || n != tt.n {
+			t.Errorf("fromLetters(%q) = %d, %v, want %d, true", got, n, ok, tt.n)
+		}
+	}
+	for n := 1; n <= 1000; n++ {
+		if m, ok := fromLetters(toLetters(n)); !ok || m != n {
+			t.Fatalf("fromLetters(toLetters(%d)) = %d, %v", n, m, ok)
+		}
+	}
+	if n, ok := fromLetters("AA"); !ok || n != 27 {
+		t.Errorf("fromLetters(\"AA\") = %d, %v, want 27, true", n, ok)
+	}
+	for _, s := range []string{"", "a1", "é", " a"} {
+		if n, ok := fromLetters(s); ok {
+			t.Errorf("fromLetters(%q) = %d, true, want false", s, n)
+		}
+	}
+}
+
+// TestListJSON pins the shape of `list -o json`, which scripts and bars
+// parse: field names, which fields are omitted when empty, and the
+// dynamic placeholder row.
+func TestListJSON(t *testing.T) {
+	setConfig(t, &Config{Workspaces: []WorkspaceMeta{
+		{Name: "Web", Color: "#89b4fa", Note: "tickets"},
+		{Name: "Code"},
+		{Name: "R&D <tmp>", Icon: "~/icons/lab.png"},
+		{Name: "Spare", Icon: "x", Color: "red", Note: "hidden"},
+	}})
+	var buf bytes.Buffer
+	entries := listEntries(4, 1, true, map[int]int{0: 2, 1: 1, 3: 0})
+	if err := json.NewEncoder(&buf).Encode(entries); err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"index":1,"name":"Web","active":false,"windows":2,"color":"#89b4fa","note":"tickets","dynamic_placeholder":false},` +
+		`{"index":2,"name":"Code","active":true,"windows":1,"dynamic_placeholder":false},` +
+		`{"index":3,"name":"R\u0026D \u003ctmp\u003e","active":false,"windows":0,"icon":"~/icons/lab.png","dynamic_placeholder":false},` +
+		`{"index":4,"name":"New Workspace","active":false,"windows":0,"dynamic_placeholder":true}` +
+		"]\n"
+	if got := buf.String(); got != want {
+		t.Errorf("list JSON:\n got %s\nwant %s", got, want)
+	}
+
+	buf.Reset()
+	if err := json.NewEncoder(&buf).Encode(listEntries(0, -1, false, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("empty list JSON = %q, want \"[]\\n\"", got)
+	}
+}
+
+func TestNotifySummary(t *testing.T) {
+	setBackend(t, &fakeBackend{count: 2})
+	setConfig(t, &Config{Workspaces: []WorkspaceMeta{{Name: "Web", Icon: ""}, {Name: "Code"}}})
+	if got, err := notifySummary(1); err != nil || got != " Web" {
+		t.Errorf("notifySummary(1) = %q, %v, want the icon and name", got, err)
+	}
+	cfg.NotifyFormat = "{{.Label}}/{{.Name}}"
+	if got, err := notifySummary(2); err != nil || got != "2/Code" {
+		t.Errorf("notifySummary(2) = %q, %v, want 2/Code", got, err)
+	}
+	cfg.NotifyFormat = "{{.Nope}}"
+	if got, err := notifySummary(2); err == nil {
+		t.Errorf("notifySummary with a bad field = %q, want an error", got)
+	}
+}
+
+func TestFriendlyClassName(t *testing.T) {
+	setConfig(t, &Config{ClassNameMap: map[string]string{
+		"navigator.firefox": "Browser",
+		"Firefox":           "Fox",
+		"firefox":           "fox",
+		"FIREFOX":           "FOX",
+		"code":              "Editor",
+		"kitty":             "Term",
+	}})
+	tests := []struct{ in, want string }{
+		{"Navigator.Firefox", "Browser"}, // full string before class
+		{"Private.Firefox", "Fox"},       // exact class before other cases
+		{"Private.firefox", "fox"},
+		{"Private.FireFox", "FOX"},  // sorted order among case variants
+		{"code.Code", "Editor"},     // class, case-insensitive
+		{"kitty.Alacritty", "Term"}, // instance
+		{"xterm.XTerm", "XTerm"},
+		{"bare", "bare"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := friendlyClassName(tt.in); got != tt.want {
+				t.Fatalf("friendlyClassName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestRankNames(t *testing.T) {
+	names := []string{"Chat", "Web", "Webcam", "Dev Web", "w-e-b", "Mail"}
+	want := []int{1, 2, 3, 4}
+	if got := rankNames(names, "web"); !reflect.DeepEqual(got, want) {
+		t.Errorf("rankNames(web) = %v, want %v", got, want)
+	}
+	if got := rankNames(names, "zzz"); len(got) != 0 {
+		t.Errorf("rankNames(zzz) = %v, want none", got)
+	}
+}
+
+func TestUpdateConfigKeepsBrokenFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	setConfig(t, &Config{})
+	oldFile, oldErr := configFile, configErr
+	t.Cleanup(func() { configFile, configErr = oldFile, oldErr })
+	configFile = filepath.Join(dir, "workspaces.yaml")
+
+	broken := []byte("workspaces:\n  - name: [oops\n")
+	if err := os.WriteFile(configFile, broken, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(); err == nil {
+		t.Fatal("loadConfig of a broken file succeeded")
+	}
+	rename := func() error {
+		ensureWorkspaces(1)
+		cfg.Workspaces[0].Name = "Web"
+		return nil
+	}
+	if err := updateConfig(rename); err == nil {
+		t.Error("updateConfig saved over a broken file")
+	}
+	if err := saveConfig(); err == nil {
+		t.Error("saveConfig saved over a broken file")
+	}
+	if b, _ := os.ReadFile(configFile); !bytes.Equal(b, broken) {
+		t.Errorf("broken config was rewritten to %q", b)
+	}
+
+	// Once the file is fixed, the next update reads it again and saves.
+	if err := os.WriteFile(configFile, []byte("workspaces:\n  - name: Mail\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(configFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateConfig(rename); err != nil {
+		t.Fatalf("updateConfig after the fix: %v", err)
+	}
+	if got := nameForIndex(0); got != "Web" {
+		t.Errorf("name after update = %q, want Web", got)
+	}
+}
+
+func TestWriteConfigThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	setConfig(t, &Config{Workspaces: []WorkspaceMeta{{Name: "Web"}}})
+	oldFile, oldErr := configFile, configErr
+	t.Cleanup(func() { configFile, configErr = oldFile, oldErr })
+	configErr = nil
+
+	real := filepath.Join(dir, "dotfiles", "workspaces.yaml")
+	if err := os.MkdirAll(filepath.Dir(real), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(real, []byte("workspaces: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile = filepath.Join(dir, "config", "workspaces.yaml")
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Lstat(configFile); err != nil || st.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("config is no longer a symlink: %v", err)
+	}
+	b, err := os.ReadFile(real)
+	if err != nil || !bytes.Contains(b, []byte("Web")) {
+		t.Errorf("target = %q, %v, want the saved config", b, err)
+	}
+	if _, err := os.Stat(real + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestWatchConfigThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := configFile
+	t.Cleanup(func() { configFile = oldFile })
+
+	real := filepath.Join(dir, "dotfiles", "workspaces.yaml")
+	if err := os.MkdirAll(filepath.Dir(real), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(real, []byte("workspaces: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile = filepath.Join(dir, "config", "workspaces.yaml")
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(real, configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	changed := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- watchConfig(stop, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}()
+	defer func() {
+		close(stop)
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// Edit the target, as a dotfiles checkout would, until the watcher
+	// is up and reports it.
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := os.WriteFile(real, []byte("workspaces: [{name: Web}]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changed:
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("no change reported for an edit of the symlink's target")
+		}
+	}
+}
+
+func TestMigrateLauncherStyles(t *testing.T) {
+	setConfig(t, &Config{
+		Workspaces: []WorkspaceMeta{{Name: "Web"}, {Name: "Games", LauncherStyle: "kept.css"}, {Name: "Code"}},
+		WorkspaceLauncherStyles: map[string]string{
+			"Web": "web.css", "Games": "games.css", "Gone": "gone.css",
+		},
+	})
+	if !migrateConfig() {
+		t.Fatal("migrateConfig reported nothing to migrate")
+	}
+	var got []string
+	for _, w := range cfg.Workspaces {
+		got = append(got, w.LauncherStyle)
+	}
+	if want := []string{"web.css", "kept.css", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("styles = %q, want %q", got, want)
+	}
+	if cfg.WorkspaceLauncherStyles != nil {
+		t.Errorf("old map kept: %v", cfg.WorkspaceLauncherStyles)
+	}
+	if migrateConfig() {
+		t.Error("migrateConfig migrated twice")
+	}
+}
+
+func TestRepairConfigKeepsConcurrentEdit(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	setConfig(t, &Config{})
+	oldFile, oldErr := configFile, configErr
+	t.Cleanup(func() { configFile, configErr = oldFile, oldErr })
+	configFile = filepath.Join(dir, "workspaces.yaml")
+
+	if err := os.WriteFile(configFile, []byte("workspaces:\n  - name: \"\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// Another process renames a workspace after this one loaded the file.
+	if err := os.WriteFile(configFile, []byte("workspaces:\n  - name: \"\"\n  - name: Chat\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(configFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := repairConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("changes = %q, want one", changes)
+	}
+	if got := []string{nameForIndex(0), nameForIndex(1)}; !reflect.DeepEqual(got, []string{"Workspace 1", "Chat"}) {
+		t.Errorf("names = %q, want the repair and the other edit", got)
+	}
+}
+
+// fakeBackend is a WorkspaceBackend with count workspaces, active (0-based)
+// being the active one.
+type fakeBackend struct{ count, active int }
+
+func (b *fakeBackend) Count() (int, error)       { return b.count, nil }
+func (b *fakeBackend) ActiveIndex() (int, error) { return b.active, nil }
+func (b *fakeBackend) Switch(idx int) error      { b.active = idx; return nil }
+func (b *fakeBackend) SetCount(n int) error      { b.count = n; return nil }
+func (b *fakeBackend) Dynamic() (bool, error)    { return false, nil }
+func (b *fakeBackend) SetDynamic(bool) error     { return nil }
+
+// setBackend makes b the backend for the rest of the test.
+func setBackend(t *testing.T, b WorkspaceBackend) {
+	t.Helper()
+	old := backend
+	backend = b
+	t.Cleanup(func() { backend = old })
+}
+
+// outputBackend is a fakeBackend whose workspaces sit on outputs.
+type outputBackend struct {
+	fakeBackend
+	outputs map[int]string
+}
+
+func (b *outputBackend) WorkspaceOutputs() (map[int]string, error) { return b.outputs, nil }
+
+func TestOffScreenWorkspaces(t *testing.T) {
+	// Workspaces 3 and 4 don't exist, so they are on no output.
+	outputs := map[int]string{0: "DP-1", 1: "HDMI-1", 2: "DP-1", 5: "HDMI-1"}
+	tests := []struct {
+		active, delta int
+		wrap          bool
+		want          int // 1-based
+		ok            bool
+	}{
+		{0, 1, true, 3, true},
+		{2, 1, true, 1, true},
+		{2, 1, false, 3, false},
+		{0, -1, true, 3, true},
+		{1, 1, true, 6, true},
+		{5, 1, true, 2, true},
+	}
+	for _, tt := range tests {
+		setBackend(t, &outputBackend{fakeBackend{count: 6, active: tt.active}, outputs})
+		skip, err := offScreenWorkspaces()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok, err := adjacentWorkspace(tt.delta, tt.wrap, skip)
+		if err != nil || got != tt.want || ok != tt.ok {
+			t.Errorf("from %d, adjacentWorkspace(%d, %v) = %d, %v, %v, want %d, %v",
+				tt.active, tt.delta, tt.wrap, got, ok, err, tt.want, tt.ok)
+		}
+	}
+
+	setBackend(t, &fakeBackend{count: 3})
+	if _, err := offScreenWorkspaces(); err == nil {
+		t.Error("offScreenWorkspaces on a backend without outputs = nil error")
+	}
+}
+
+func TestAdjacentWorkspace(t *testing.T) {
+	// Workspaces 0-based; occupied lists the ones with windows.
+	tests := []struct {
+		name     string
+		active   int
+		delta    int
+		wrap     bool
+		occupied []int // nil: no skip
+		want     int   // 1-based
+		ok       bool
+	}{
+		{"next", 1, 1, true, nil, 3, true},
+		{"prev", 1, -1, true, nil, 1, true},
+		{"wrap forward", 4, 1, true, nil, 1, true},
+		{"wrap back", 0, -1, true, nil, 5, true},
+		{"off the end", 4, 1, false, nil, 5, false},
+		{"off the start", 0, -1, false, nil, 1, false},
+		{"two steps", 1, 2, true, nil, 4, true},
+		{"skip empty", 0, 1, true, []int{0, 3}, 4, true},
+		{"skip empty back", 0, -1, true, []int{0, 3}, 4, true},
+		{"skip empty without wrap", 3, 1, false, []int{0, 3}, 4, false},
+		{"only the active one occupied", 2, 1, true, []int{2}, 3, false},
+		{"only the active one occupied back", 2, -1, true, []int{2}, 3, false},
+		{"nothing occupied", 2, 1, true, []int{}, 3, false},
+		{"active empty, other occupied", 2, 1, true, []int{1}, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setBackend(t, &fakeBackend{count: 5, active: tt.active})
+			var skip func(int) bool
+			if tt.occupied != nil {
+				occ := map[int]bool{}
+				for _, i := range tt.occupied {
+					occ[i] = true
+				}
+				skip = func(i int) bool { return !occ[i] }
+			}
+			got, ok, err := adjacentWorkspace(tt.delta, tt.wrap, skip)
+			if err != nil || got != tt.want || ok != tt.ok {
+				t.Errorf("adjacentWorkspace(%d, %v) = %d, %v, %v, want %d, %v",
+					tt.delta, tt.wrap, got, ok, err, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,651 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// -----------------------------------------------------------------------------
+// Launcher integration
+// -----------------------------------------------------------------------------
+
+// launcher describes how to run a dmenu-style program. Launchers without
+// Pango markup support get a plain prefix on the active row instead (see
+// menuActiveMarkers). promptFlag sets the prompt of the follow-up questions
+// asked by menu actions. Terminal launchers draw on the terminal, so their
+// stderr is kept.
+type launcher struct {
+	args       []string
+	markup     bool
+	terminal   bool
+	promptFlag string
+}
+
+var launchers = map[string]launcher{
+	"wofi":   {args: []string{"--show", "dmenu", "-i", "--allow-images", "--allow-markup"}, markup: true, promptFlag: "--prompt"},
+	"rofi":   {args: []string{"-dmenu", "-i", "-markup-rows"}, markup: true, promptFlag: "-p"},
+	"dmenu":  {args: []string{"-i"}, promptFlag: "-p"},
+	"fuzzel": {args: []string{"--dmenu"}, promptFlag: "--prompt"},
+	"tofi":   {promptFlag: "--prompt-text"},
+	"bemenu": {args: []string{"-i"}, promptFlag: "-p"},
+	"fzf":    {args: []string{"--prompt", menuPrompt + "> ", "--no-sort"}, terminal: true},
+}
+
+var launcherNames = []string{"wofi", "rofi", "dmenu", "fuzzel", "tofi", "bemenu", "fzf"}
+
+func unknownLauncherError(name string) error {
+	return fmt.Errorf("unknown launcher %q (supported: %s)", name, strings.Join(launcherNames, ", "))
+}
+
+// wofiIntegration prints the menu, with Pango markup unless plain is set
+// or markup is turned off (see menuPlain).
+func wofiIntegration(plain bool) error {
+	markup := !plain && !menuPlain()
+	menu, err := buildMenu(markup, markup)
+	if err != nil {
+		return err
+	}
+	fmt.Print(menu)
+	return nil
+}
+
+func parseWofiSelection() error {
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return errors.New("no input")
+	}
+	line := strings.TrimSpace(scanner.Text())
+	if line == "" {
+		return errors.New("empty input")
+	}
+	return switchToMenuLine(line)
+}
+
+// switchToMenuLine switches to the workspace named by a selected menu line.
+func switchToMenuLine(line string) error {
+	idx, err := parseMenuLine(line)
+	if err != nil {
+		return err
+	}
+	return switchWorkspace(idx)
+}
+
+// parseMenuLine extracts the 1-based workspace index from an "idx: name"
+// menu line. Launchers rendering markup hand back the row as written, so
+// Pango tags and escapes are undone first, and the active row's prefix is
+// skipped. Only the leading token is parsed, so names may contain colons
+// or look like numbers themselves.
+func parseMenuLine(line string) (int, error) {
+	line = menuText(line)
+	for _, prefix := range []string{cfg.MenuActivePrefix, "* "} {
+		if prefix != "" && strings.HasPrefix(line, prefix) {
+			line = strings.TrimSpace(strings.TrimPrefix(line, prefix))
+			break
+		}
+	}
+	end := strings.IndexFunc(line, func(r rune) bool {
+		return r == ':' || unicode.IsSpace(r)
+	})
+	if end <= 0 || line[end] != ':' {
+		return 0, errors.New("invalid format: 'idx: name'")
+	}
+	return parseIndex(line[:end])
+}
+
+// menuText is a selected menu line with wofi's image prefix, Pango tags
+// and escapes undone.
+func menuText(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "img:") {
+		if _, text, ok := strings.Cut(line, ":text:"); ok {
+			line = text
+		}
+	}
+	line = unescapeMarkup(markupTag.ReplaceAllString(line, ""))
+	return strings.TrimSpace(line)
+}
+
+// menuPrompt is what {prompt} expands to in menu_command.
+const menuPrompt = "Workspace"
+
+// menuCommand returns the command line and markup support of the named
+// launcher. An empty name means menu_command when configured, else wofi,
+// followed by menu_args. prompt replaces menuPrompt in menu_command and is
+// passed to built-in launchers when set; they keep their own otherwise.
+func menuCommand(name, prompt string) (argv []string, markup bool, err error) {
+	if name == "" && cfg.MenuCommand != "" {
+		if prompt == "" {
+			prompt = menuPrompt
+		}
+		argv, err := expandMenuCommand(cfg.MenuCommand, prompt, launcherStyle())
+		return append(argv, cfg.MenuArgs...), cfg.MenuMarkup, err
+	}
+	if name == "" {
+		argv, markup, err = menuCommand("wofi", prompt)
+		return append(argv, cfg.MenuArgs...), markup, err
+	}
+	l, ok := launchers[name]
+	if !ok {
+		return nil, false, unknownLauncherError(name)
+	}
+	argv = append([]string{name}, l.args...)
+	if style := launcherStyle(); name == "wofi" && style != "" {
+		argv = append(argv, "--style", style)
+	}
+	if prompt != "" && l.promptFlag != "" {
+		argv = append(argv, l.promptFlag, prompt)
+	}
+	return argv, l.markup, nil
+}
+
+// expandMenuCommand splits a menu_command template into words, honouring
+// single and double quotes, and fills in {prompt} and {style}. A word that
+// is only a placeholder is dropped when it expands to nothing.
+func expandMenuCommand(tmpl, prompt, style string) ([]string, error) {
+	words, err := splitWords(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	r := strings.NewReplacer("{prompt}", prompt, "{style}", style)
+	var argv []string
+	for _, w := range words {
+		x := r.Replace(w)
+		if x == "" && w != "" {
+			continue
+		}
+		argv = append(argv, x)
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("menu_command is empty")
+	}
+	return argv, nil
+}
+
+// splitWords splits s on whitespace like a shell would, without expansion.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// runMenu shows the workspace list in the named launcher (see menuCommand),
+// with extra appended to its arguments, and switches to the selection.
+// Graphical launchers also list menuActions below the workspaces.
+// Dismissing the launcher is not an error.
+func runMenu(name string, extra []string) error {
+	argv, markup, err := menuCommand(name, "")
+	if err != nil {
+		return err
+	}
+	argv = append(argv, extra...)
+	markup = markup && !menuPlain()
+	launcherName := filepath.Base(argv[0])
+	menu, err := buildMenu(markup, markup && launcherName == "wofi")
+	if err != nil {
+		return err
+	}
+	withActions := !cfg.HideMenuActions && !launchers[launcherName].terminal
+	if withActions {
+		menu += menuActionRows(markup)
+	}
+	sel, code, err := launch(argv, menu)
+	if err != nil {
+		return err
+	}
+	if withActions {
+		// rofi reports kb-custom-n (Alt+n by default) as exit status 9+n.
+		if a := code - 10; launcherName == "rofi" && a >= 0 && a < len(menuActions) {
+			return menuActions[a].run(menuAsker(name, extra))
+		}
+		for _, a := range menuActions {
+			if menuText(sel) == a.label {
+				return a.run(menuAsker(name, extra))
+			}
+		}
+	}
+	if sel == "" {
+		return nil
+	}
+	return switchToMenuLine(sel)
+}
+
+// launch runs a launcher with input on stdin and returns the selected line
+// and its exit status. A launcher exiting non-zero was dismissed, so that
+// is reported with an empty selection rather than an error.
+func launch(argv []string, input string) (sel string, code int, err error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(input)
+	if l, ok := launchers[filepath.Base(argv[0])]; ok && l.terminal {
+		cmd.Stderr = os.Stderr
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", exitErr.ExitCode(), nil
+		}
+		return "", 0, fmt.Errorf("%s: %v", argv[0], err)
+	}
+	return strings.TrimSpace(string(out)), 0, nil
+}
+
+// launcherStyle picks the wofi style for the active workspace, falling back
+// to the global launcher_style.
+func launcherStyle() string {
+	if idx, err := getActiveWorkspaceIndex(); err == nil {
+		if style := workspaceMeta(idx).LauncherStyle; style != "" {
+			return style
+		}
+	}
+	return cfg.LauncherStyle
+}
+
+// -----------------------------------------------------------------------------
+// Menu actions
+// -----------------------------------------------------------------------------
+
+// menuAction is a row listed after the workspaces in interactive menus. run
+// gets an asker that puts a follow-up question to the same launcher.
+type menuAction struct {
+	label string
+	run   func(ask func(prompt string) (string, bool, error)) error
+}
+
+// menuActions are listed in this order; rofi binds them to kb-custom-1 to
+// kb-custom-3 as well.
+var menuActions = []menuAction{
+	{"+ New workspace…", newWorkspaceAction},
+	{"✎ Rename current…", renameCurrentAction},
+	{"✕ Close empty workspaces", closeEmptyAction},
+}
+
+func menuActionRows(markup bool) string {
+	var b strings.Builder
+	for _, a := range menuActions {
+		label := a.label
+		if markup {
+			label = "<i>" + escapeMarkup(label) + "</i>"
+		}
+		b.WriteString(label + "\n")
+	}
+	return b.String()
+}
+
+// menuAsker returns a function that shows the named launcher with no rows
+// and the given prompt, and returns what was typed. ok is false when the
+// launcher was dismissed. What was typed isn't markup, so names such as
+// "<tmp>" or "R&amp;D" are kept as written.
+func menuAsker(name string, extra []string) func(string) (string, bool, error) {
+	return func(prompt string) (string, bool, error) {
+		argv, _, err := menuCommand(name, prompt)
+		if err != nil {
+			return "", false, err
+		}
+		sel, code, err := launch(append(argv, extra...), "")
+		return sel, err == nil && code == 0, err
+	}
+}
+
+// newWorkspaceAction asks for a name, adds a workspace with it and switches
+// there. With dynamic workspaces GNOME's empty trailing slot is used.
+func newWorkspaceAction(ask func(string) (string, bool, error)) error {
+	name, ok, err := ask("New workspace")
+	if err != nil || !ok {
+		return err
+	}
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return err
+	}
+	idx := sc + 1
+	if dyn, _ := getDynamic(); dyn {
+		idx = sc
+	} else if err := createWorkspaces(idx, false); err != nil {
+		return err
+	}
+	if name != "" {
+		if err := renameLocal(idx, name); err != nil {
+			return err
+		}
+	}
+	return switchWorkspace(idx)
+}
+
+// renameCurrentAction asks for a new name for the active workspace.
+func renameCurrentAction(ask func(string) (string, bool, error)) error {
+	active, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return err
+	}
+	name, ok, err := ask("Rename " + nameForIndex(active))
+	if err != nil || !ok || name == "" {
+		return err
+	}
+	return renameLocal(active+1, name)
+}
+
+func closeEmptyAction(func(string) (string, bool, error)) error {
+	_, err := closeEmptyWorkspaces()
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Menu cache
+// -----------------------------------------------------------------------------
+
+// menuCacheTTL bounds how long a rendered menu is reused; repeated key-bound
+// launches within this window skip the backend and gsettings round trips.
+const menuCacheTTL = time.Second
+
+// cacheDir is the gnav directory under $XDG_CACHE_HOME, or ~/.cache
+// without it.
+func cacheDir(getenv func(string) string) string {
+	dir := getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		dir = filepath.Join(getenv("HOME"), ".cache")
+	}
+	return filepath.Join(dir, "gnav")
+}
+
+var menuCacheFile = filepath.Join(cacheDir(os.Getenv), "menu")
+
+// menuCachePath is the cached menu for the flavour and order given. The
+// config file is part of the key, so profiles and --config never get each
+// other's names.
+func menuCachePath(markup, images bool, order string) string {
+	h := fnv.New32a()
+	h.Write([]byte(absConfigFile()))
+	base := fmt.Sprintf("%s-%08x", menuCacheFile, h.Sum32())
+	path := base + "-plain"
+	switch {
+	case images:
+		path = base + "-images"
+	case markup:
+		path = base
+	}
+	if order != menuOrderIndex {
+		path += "-" + order
+	}
+	return path
+}
+
+// buildMenu renders one "idx: name" line per workspace, with the icon in
+// front of the name. The active row gets the configured markers and, with
+// markup, is wrapped in a Pango span, and notes follow in small italics.
+// Image icons are only shown with images, in wofi's img:path:text: form;
+// other menus leave them out.
+func buildMenu(markup, images bool) (string, error) {
+	order := currentMenuOrder()
+	cache := menuCachePath(markup, images, order)
+	if st, err := os.Stat(cache); err == nil && time.Since(st.ModTime()) < menuCacheTTL {
+		if b, err := ioutil.ReadFile(cache); err == nil {
+			return string(b), nil
+		}
+	}
+	if err := loadConfig(); err != nil {
+		return "", err
+	}
+	dyn, _ := getDynamic()
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return "", err
+	}
+	activeIdx, _ := getActiveWorkspaceIndex()
+
+	var buf bytes.Buffer
+	for _, i := range menuOrderIndices(order, sc, activeIdx, dyn) {
+		buf.WriteString(menuLine(i, sc, activeIdx, dyn, markup, images) + "\n")
+	}
+	menu := buf.String()
+	if err := os.MkdirAll(filepath.Dir(cache), 0755); err == nil {
+		_ = ioutil.WriteFile(cache, []byte(menu), 0644)
+	}
+	return menu, nil
+}
+
+// menuLine renders the menu row of the 0-based workspace i out of sc, in
+// the format parseMenuLine reads back.
+func menuLine(i, sc, activeIdx int, dyn, markup, images bool) string {
+	name := displayName(i, sc, dyn)
+	icon := workspaceMeta(i).Icon
+	if dyn && i == sc-1 {
+		icon = ""
+	}
+	if glyph := iconText(icon); glyph != "" {
+		name = glyph + " " + name
+	}
+	line := fmt.Sprintf("%s: %s", formatIndex(i), name)
+	if i == activeIdx {
+		prefix, suffix := menuActiveMarkers(markup)
+		line = prefix + line + suffix
+	}
+	if markup {
+		line = menuMarkup(escapeMarkup(line), workspaceMeta(i).Color, i == activeIdx)
+		if note := workspaceMeta(i).Note; note != "" && !(dyn && i == sc-1) {
+			line += "  <small><i>" + escapeMarkup(note) + "</i></small>"
+		}
+	}
+	if images && iconIsImage(icon) {
+		line = "img:" + expandHome(icon) + ":text:" + line
+	}
+	return line
+}
+
+// markupEscaper escapes the characters Pango markup reserves.
+var markupEscaper = strings.NewReplacer(
+	"&", "&amp;", "<", "&lt;", ">", "&gt;", "'", "&apos;", `"`, "&quot;")
+
+// escapeMarkup makes s safe to embed in Pango markup, so names such as
+// "R&D" or "<tmp>" show as written instead of breaking the whole menu.
+func escapeMarkup(s string) string {
+	return markupEscaper.Replace(s)
+}
+
+// markupTag matches a Pango tag such as <span foreground='red'> or </b>.
+// Names are escaped in markup rows, so none of their text can match.
+var markupTag = regexp.MustCompile(`</?[a-zA-Z]+(\s[^>]*)?>`)
+
+// unescapeMarkup reverses escapeMarkup, and any other entity references, for
+// launchers that hand back the selected row as it was written.
+func unescapeMarkup(s string) string {
+	return html.UnescapeString(s)
+}
+
+// menuPlain reports whether menus must be plain text, because menu_plain
+// is set or NO_COLOR asks for no colors (https://no-color.org).
+func menuPlain() bool {
+	return cfg.MenuPlain || os.Getenv("NO_COLOR") != ""
+}
+
+// menuActiveMarkers returns the text put before and after the active row.
+// Plain menus can't highlight it, so they default to a "* " prefix.
+func menuActiveMarkers(markup bool) (prefix, suffix string) {
+	prefix = cfg.MenuActivePrefix
+	if prefix == "" && !markup {
+		prefix = "* "
+	}
+	return prefix, cfg.MenuActiveSuffix
+}
+
+// menuMarkup colors a menu line with the workspace's color. Uncolored
+// workspaces stay plain except the active one, which is shown in the
+// theme's wofi_active color; a colored active row, or the active row when
+// the theme has no such color, is made bold instead so it still stands out.
+// menu_active_markup replaces all of this for the active row.
+func menuMarkup(line, color string, active bool) string {
+	if active && cfg.MenuActiveMarkup != "" {
+		if color == "" {
+			color = currentTheme().WofiActive
+		}
+		return strings.NewReplacer("{line}", line, "{color}", color).Replace(cfg.MenuActiveMarkup)
+	}
+	switch {
+	case color != "" && active:
+		return fmt.Sprintf("<span foreground='%s' weight='bold'>%s</span>", color, line)
+	case color != "":
+		return fmt.Sprintf("<span foreground='%s'>%s</span>", color, line)
+	case active:
+		if c := currentTheme().WofiActive; c != "" {
+			return fmt.Sprintf("<span foreground='%s'>%s</span>", c, line)
+		}
+		return fmt.Sprintf("<span weight='bold'>%s</span>", line)
+	}
+	return line
+}
+
+// invalidateMenuCache drops the cached menus after anything that changes
+// names, the workspace count or the active workspace.
+func invalidateMenuCache() {
+	for _, order := range menuOrders {
+		_ = os.Remove(menuCachePath(true, true, order))
+		_ = os.Remove(menuCachePath(true, false, order))
+		_ = os.Remove(menuCachePath(false, false, order))
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Menu order
+// -----------------------------------------------------------------------------
+
+// Menus list workspaces by index, or with menu_order (or --order) by
+// frecency, from the switch log, or by most recent use, from the history.
+// Outside index order the active workspace goes last, as picking it does
+// nothing, and so does GNOME's empty trailing workspace.
+const (
+	menuOrderIndex    = "index"
+	menuOrderFrecency = "frecency"
+	menuOrderMRU      = "mru"
+)
+
+var menuOrders = []string{menuOrderIndex, menuOrderFrecency, menuOrderMRU}
+
+// menuOrderFlag is the --order flag of the menu commands; it overrides
+// menu_order when set.
+var menuOrderFlag string
+
+func currentMenuOrder() string {
+	for _, o := range []string{menuOrderFlag, cfg.MenuOrder} {
+		for _, known := range menuOrders {
+			if strings.EqualFold(o, known) {
+				return known
+			}
+		}
+	}
+	return menuOrderIndex
+}
+
+func checkMenuOrderFlag() error {
+	if menuOrderFlag == "" {
+		return nil
+	}
+	for _, known := range menuOrders {
+		if strings.EqualFold(menuOrderFlag, known) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown order %q (supported: %s)", menuOrderFlag, strings.Join(menuOrders, ", "))
+}
+
+// menuOrderIndices returns the 0-based workspaces in the order the menu
+// lists them.
+func menuOrderIndices(order string, sc, active int, dyn bool) []int {
+	all := make([]int, sc)
+	for i := range all {
+		all[i] = i
+	}
+	if order == menuOrderIndex {
+		return all
+	}
+	rank := map[int]float64{}
+	switch order {
+	case menuOrderMRU:
+		hist := loadHistory()
+		for n, idx := range hist {
+			rank[idx-1] = float64(len(hist) - n)
+		}
+	case menuOrderFrecency:
+		entries, _ := readSwitchLog()
+		rank = frecencyScores(entries, time.Now())
+	}
+	last := func(i int) bool { return i == active || dyn && i == sc-1 }
+	sort.SliceStable(all, func(a, b int) bool {
+		i, j := all[a], all[b]
+		if last(i) != last(j) {
+			return !last(i)
+		}
+		return rank[i] > rank[j]
+	})
+	return all
+}
+
+// frecencyWindow is how many of the latest switches frecency looks at.
+const frecencyWindow = 1000
+
+// frecencyScores weighs each logged switch to a workspace by how recent it
+// is and sums them per 0-based workspace.
+func frecencyScores(entries []switchEntry, now time.Time) map[int]float64 {
+	if len(entries) > frecencyWindow {
+		entries = entries[len(entries)-frecencyWindow:]
+	}
+	scores := map[int]float64{}
+	for _, e := range entries {
+		age := now.Sub(e.At)
+		var w float64
+		switch {
+		case age < 4*time.Hour:
+			w = 100
+		case age < 24*time.Hour:
+			w = 70
+		case age < 7*24*time.Hour:
+			w = 50
+		case age < 30*24*time.Hour:
+			w = 30
+		default:
+			w = 10
+		}
+		scores[e.Index-1] += w
+	}
+	return scores
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMenuLine(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name string
+		cfg  Config
+		line string
+		want int
+	}{
+		{"plain", Config{}, "3: Web", 3},
+		{"surrounding space", Config{}, "  3: Web \n", 3},
+		{"name with colons", Config{}, "2: a:b:c", 2},
+		{"name that is a number", Config{}, "4: 12", 4},
+		{"name that looks like a row", Config{}, "5: 6: Chat", 5},
+		{"icon glyph", Config{}, "1:  Term", 1},
+		{"active row", Config{}, "* 1: Web", 1},
+		{"custom active prefix", Config{MenuActivePrefix: "▶ "}, "▶ 5: Web", 5},
+		{"default prefix with custom set", Config{MenuActivePrefix: "▶ "}, "* 5: Web", 5},
+		{"markup", Config{}, "<span foreground='#fff' weight='bold'>7: Web</span>", 7},
+		{"note", Config{}, "2: Web  <small><i>todo</i></small>", 2},
+		{"image", Config{}, "img:/usr/share/icons/web.png:text:2: Web", 2},
+		{"image path with colon", Config{}, "img:/tmp/a:b.png:text:3: Web", 3},
+		{"image with markup", Config{}, "img:/i.png:text:<span weight='bold'>* 8: a:b</span>", 8},
+		{"index base 0", Config{IndexBase: &zero}, "0: Web", 1},
+		{"roman", Config{IndexStyle: "roman"}, "IV: Web", 4},
+		{"letters", Config{IndexStyle: "letters"}, "aa: Web", 27},
+		{"number under letters", Config{IndexStyle: "letters"}, "3: Web", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cfg
+			setConfig(t, &c)
+			got, err := parseMenuLine(tt.line)
+			if err != nil || got != tt.want {
+				t.Errorf("parseMenuLine(%q) = %d, %v, want %d", tt.line, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMenuLineInvalid(t *testing.T) {
+	setConfig(t, &Config{})
+	for _, line := range []string{
+		"", "Web", "3 Web", ": Web", "x: Web", "3", "img:/i.png:text:", "** 1: Web",
+	} {
+		if got, err := parseMenuLine(line); err == nil {
+			t.Errorf("parseMenuLine(%q) = %d, want an error", line, got)
+		}
+	}
+}
+
+// TestMenuLineRoundTrip renders rows the way buildMenu does and reads them
+// back the way the menus do, for names that need escaping in markup.
+func TestMenuLineRoundTrip(t *testing.T) {
+	names := []string{"R&D", "<tmp>", "a:b", `"quoted" & 'single'`, "1: fake", "&amp;"}
+	ws := make([]WorkspaceMeta, len(names))
+	for i, n := range names {
+		ws[i] = WorkspaceMeta{Name: n, Note: "<b>" + n + "</b>"}
+	}
+	ws[1].Color = "#f38ba8"
+	ws[2].Icon = "~/icons/a&b.png"
+	ws[3].Icon = ""
+	for _, c := range []Config{
+		{Workspaces: ws},
+		{Workspaces: ws, IndexStyle: "roman", MenuActivePrefix: "→ ", MenuActiveSuffix: " ←"},
+		{Workspaces: ws, MenuActiveMarkup: "<span underline='single'>{line}</span>"},
+	} {
+		c := c
+		setConfig(t, &c)
+		for i, name := range names {
+			// Plain rows are handed back exactly as written.
+			want := formatIndex(i) + ": " + name
+			line := menuLine(i, len(names), 2, false, false, false)
+			if i != 2 && line != want {
+				t.Errorf("plain row %d = %q, want %q", i, line, want)
+			}
+			if got, err := parseMenuLine(line); err != nil || got != i+1 {
+				t.Errorf("parseMenuLine(%q) = %d, %v, want %d", line, got, err, i+1)
+			}
+			for _, images := range []bool{false, true} {
+				line := menuLine(i, len(names), 2, false, true, images)
+				if got, err := parseMenuLine(line); err != nil || got != i+1 {
+					t.Errorf("parseMenuLine(%q) = %d, %v, want %d", line, got, err, i+1)
+				}
+				if i == 2 {
+					continue // the active row carries its markers
+				}
+				if text := menuText(line); !strings.HasPrefix(text, want) {
+					t.Errorf("menuText(%q) = %q, want it to start with %q", line, text, want)
+				}
+			}
+		}
+	}
+}
+
+func TestMenuCachePath(t *testing.T) {
+	getenv, _ := fakeEnv(map[string]string{"HOME": "/home/u", "XDG_CACHE_HOME": "/tmp/cache"})
+	if got := cacheDir(getenv); got != "/tmp/cache/gnav" {
+		t.Errorf("cacheDir = %q, want /tmp/cache/gnav", got)
+	}
+	getenv, _ = fakeEnv(map[string]string{"HOME": "/home/u"})
+	if got := cacheDir(getenv); got != "/home/u/.cache/gnav" {
+		t.Errorf("cacheDir = %q, want /home/u/.cache/gnav", got)
+	}
+
+	old := configFile
+	t.Cleanup(func() { configFile = old })
+	configFile = "/home/u/.config/gnav/workspaces.yaml"
+	a := menuCachePath(true, false, menuOrderIndex)
+	if b := menuCachePath(false, false, menuOrderIndex); a == b {
+		t.Errorf("markup and plain menus share %s", a)
+	}
+	configFile = "/home/u/.config/gnav/profiles/work.yaml"
+	if b := menuCachePath(true, false, menuOrderIndex); a == b {
+		t.Errorf("two config files share %s", a)
+	}
+}
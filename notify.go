@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// -----------------------------------------------------------------------------
+// Switch notifications
+// -----------------------------------------------------------------------------
+
+// With notify_on_switch, switching shows the new workspace's name in a
+// desktop notification, for desktops that otherwise only show numbers.
+// The daemon notifies for every switch it sees; without one running, gnav
+// notifies for its own switches. notify_format renders the summary from
+// the same fields as list's --format.
+
+const (
+	notificationsName  = "org.freedesktop.Notifications"
+	notificationsPath  = "/org/freedesktop/Notifications"
+	notifyTimeoutMilli = 1500
+)
+
+// switchNotifier replaces its previous notification with each new one, so
+// quick switches don't pile up.
+type switchNotifier struct {
+	id uint32
+}
+
+// notify announces the 1-based workspace idx.
+func (n *switchNotifier) notify(idx int) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+	meta := workspaceMeta(idx - 1)
+	summary, err := notifySummary(idx)
+	if err != nil {
+		return err
+	}
+	icon := ""
+	if iconIsImage(meta.Icon) {
+		icon = expandHome(meta.Icon)
+	}
+	hints := map[string]dbus.Variant{
+		// Keep switch notices out of the notification list, and let
+		// servers that support it show them in place of each other.
+		"transient":                       dbus.MakeVariant(true),
+		"x-canonical-private-synchronous": dbus.MakeVariant("gnav"),
+	}
+	return conn.Object(notificationsName, notificationsPath).Call(
+		notificationsName+".Notify", 0,
+		"gnav", n.id, icon, summary, meta.Note, []string{}, hints, int32(notifyTimeoutMilli),
+	).Store(&n.id)
+}
+
+// notifySummary is the notification text for the 1-based workspace idx.
+func notifySummary(idx int) (string, error) {
+	meta := workspaceMeta(idx - 1)
+	if cfg.NotifyFormat == "" {
+		summary := nameForIndex(idx - 1)
+		if glyph := iconText(meta.Icon); glyph != "" {
+			summary = glyph + " " + summary
+		}
+		return summary, nil
+	}
+	tmpl, err := template.New("notify_format").Parse(cfg.NotifyFormat)
+	if err != nil {
+		return "", err
+	}
+	e := listEntry{Index: idx, Name: nameForIndex(idx - 1), Active: true, Icon: meta.Icon, Color: meta.Color, Note: meta.Note}
+	if counts, err := windowCounts(); err == nil {
+		e.Windows = counts[idx-1]
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, e); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Profiles
+// -----------------------------------------------------------------------------
+
+// A profile is an alternative config file, profiles/<name>.yaml (or .toml
+// or .json) in configDir, for keeping separate workspace setups (docked at
+// work, laptop at home). The one named in configDir/profile is used unless
+// --profile picks another; "default" is the main workspaces.yaml.
+const defaultProfile = "default"
+
+// profile is the profile in use, or "" for the main config file.
+var profile string
+
+func profileFile(name string) string {
+	return findConfigFile(filepath.Join(configDir(os.Getenv), "profiles", name+".yaml"))
+}
+
+func profileMarker() string {
+	return filepath.Join(configDir(os.Getenv), "profile")
+}
+
+func checkProfileName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
+
+// savedProfile returns the profile chosen with `gnav profile use`, or ""
+// when none was.
+func savedProfile() string {
+	b, err := os.ReadFile(profileMarker())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// selectProfile points configFile at the named profile for this run. An
+// empty name or "default" selects the main config file.
+func selectProfile(name string) error {
+	if name == "" || name == defaultProfile {
+		profile = ""
+		configFile = findConfigFile(filepath.Join(configDir(os.Getenv), "workspaces.yaml"))
+		return nil
+	}
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	profile = name
+	configFile = profileFile(name)
+	return nil
+}
+
+// useProfile makes name the profile of later invocations. A profile that
+// doesn't exist yet is created with default names on its first use.
+func useProfile(name string) error {
+	if name == defaultProfile {
+		if err := os.Remove(profileMarker()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir(os.Getenv), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(profileMarker(), []byte(name+"\n"), 0644)
+}
+
+// profileNames lists "default" and every profile file, sorted.
+func profileNames() ([]string, error) {
+	names, err := configFileNames(filepath.Dir(profileFile(defaultProfile)))
+	if err != nil {
+		return nil, err
+	}
+	out := []string{defaultProfile}
+	for _, n := range names {
+		if n != defaultProfile {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// configFileNames lists the base names of the config files in dir, sorted,
+// counting a name once whatever its formats. A missing dir has none.
+func configFileNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		n := strings.TrimSuffix(e.Name(), ext)
+		if e.IsDir() || seen[n] || !isConfigExtension(ext) {
+			continue
+		}
+		seen[n] = true
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Session restore
+// -----------------------------------------------------------------------------
+
+// restoreWorkspaces makes the system match the config: the dynamic mode and
+// count from the dynamic and count keys, and on GNOME the names in the
+// overview. It returns one line per change made.
+func restoreWorkspaces() ([]string, error) {
+	var changes []string
+	dyn, err := getDynamic()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Dynamic != nil && *cfg.Dynamic != dyn {
+		if err := setDynamic(*cfg.Dynamic); err != nil {
+			return changes, err
+		}
+		changes = append(changes, fmt.Sprintf("dynamic: %s -> %s", onOff(dyn), onOff(*cfg.Dynamic)))
+		dyn = *cfg.Dynamic
+	}
+
+	// i3, sway and Hyprland create workspaces as they are used, so there
+	// is no count to restore there.
+	if !dyn && !onDemandWorkspaces() {
+		sc, err := getSystemWorkspaceCount()
+		if err != nil {
+			return changes, err
+		}
+		want := cfg.Count
+		if want == 0 && len(cfg.Workspaces) > sc {
+			want = len(cfg.Workspaces)
+		}
+		if want > 0 && want != sc {
+			// Shrinking goes through set-count's checks, so windows on the
+			// workspaces past want aren't left on ones that no longer exist.
+			if want < sc {
+				err = setWorkspaceCount(want, false)
+			} else {
+				invalidateMenuCache()
+				err = backend.SetCount(want)
+			}
+			if err != nil {
+				return changes, err
+			}
+			changes = append(changes, fmt.Sprintf("count: %d -> %d", sc, want))
+		}
+	}
+
+	if isGNOME(os.Getenv) && len(cfg.Workspaces) > 0 {
+		remote, err := gnomeWorkspaceNames()
+		if err != nil {
+			return changes, err
+		}
+		if formatStringArray(remote) != formatStringArray(gnavNames()) {
+			if err := pushNames(); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "names: pushed to GNOME")
+		}
+	}
+	return changes, nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+const restoreUnitName = "gnav-restore.service"
+
+// restoreUnit is a systemd user unit running `gnav restore` once per
+// graphical session. args are extra global flags, such as --config.
+func restoreUnit(exe string, args []string) string {
+	cmd := []string{systemdQuote(exe)}
+	for _, a := range args {
+		cmd = append(cmd, systemdQuote(a))
+	}
+	cmd = append(cmd, "restore")
+	return fmt.Sprintf(`[Unit]
+Description=Restore gnav workspace count, mode and names
+PartOf=graphical-session.target
+After=graphical-session.target
+
+[Service]
+Type=oneshot
+ExecStart=%s
+
+[Install]
+WantedBy=graphical-session.target
+`, strings.Join(cmd, " "))
+}
+
+// systemdQuote quotes s for an ExecStart line when it needs it.
+func systemdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\$%;") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// systemdUserDir is where user units go, $XDG_CONFIG_HOME/systemd/user.
+func systemdUserDir(getenv func(string) string) string {
+	return filepath.Join(filepath.Dir(configDir(getenv)), "systemd", "user")
+}
+
+// installRestoreService writes the restore unit and, with enable, enables
+// it through systemctl. It returns the unit's path.
+func installRestoreService(args []string, enable bool) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	dir := systemdUserDir(os.Getenv)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, restoreUnitName)
+	if err := ioutil.WriteFile(path, []byte(restoreUnit(exe, args)), 0644); err != nil {
+		return "", err
+	}
+	if !enable {
+		return path, nil
+	}
+	for _, argv := range [][]string{
+		{"systemctl", "--user", "daemon-reload"},
+		{"systemctl", "--user", "enable", restoreUnitName},
+	} {
+		if out, err := exec.Command(argv[0], argv[1:]...).CombinedOutput(); err != nil {
+			return path, fmt.Errorf("%s: %v: %s", strings.Join(argv, " "), err, strings.TrimSpace(string(out)))
+		}
+	}
+	return path, nil
+}
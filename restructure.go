@@ -0,0 +1,423 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Restructuring workspaces
+// -----------------------------------------------------------------------------
+
+// Window managers only add or remove workspaces at the end and can't
+// reorder them. Removing or inserting one in the middle therefore moves the
+// windows after it down or up a workspace, reordering moves the windows of
+// the workspaces involved, and the names are shifted to stay with their
+// windows.
+
+// relocateWindows moves every window on a workspace to to(its 0-based
+// desktop), when that differs. Sticky windows stay where they are.
+func relocateWindows(wins []windowInfo, to func(int) int) error {
+	for _, w := range wins {
+		if w.Desktop < 0 {
+			continue
+		}
+		if d := to(w.Desktop); d != w.Desktop {
+			if err := moveWindow(w.ID, d+1); err != nil {
+				return fmt.Errorf("moving %q: %v", w.Title, err)
+			}
+		}
+	}
+	return nil
+}
+
+// requireWindowControl refuses to verb workspaces when their windows can't
+// all be moved along with the names. Backends without their own window
+// list and moves fall back to wmctrl, which under Wayland only sees
+// XWayland windows, so the native ones would stay put and end up under
+// another workspace's name.
+func requireWindowControl(verb string) error {
+	_, lists := backend.(windowLister)
+	_, moves := backend.(windowController)
+	if lists && moves || sessionType(os.Getenv) != "wayland" {
+		return nil
+	}
+	return fmt.Errorf("can't %s workspaces with this backend on Wayland: it can't move windows between workspaces", verb)
+}
+
+// staticCount returns the system workspace count, refusing when dynamic
+// workspaces are on or the backend has no count to set: the desktop then
+// adds and removes workspaces by itself.
+func staticCount(verb string) (int, error) {
+	if onDemandWorkspaces() {
+		return 0, fmt.Errorf("can't %s workspaces: %v", verb, errCountUnsupported)
+	}
+	if dyn, _ := getDynamic(); dyn {
+		return 0, fmt.Errorf("dynamic workspaces are on; turn them off to %s workspaces", verb)
+	}
+	return getSystemWorkspaceCount()
+}
+
+// deleteTarget is where deleting the 1-based workspace idx sends its
+// windows by default: the workspace before it, or the next for the first.
+func deleteTarget(idx int) int {
+	if idx == 1 {
+		return 2
+	}
+	return idx - 1
+}
+
+// deleteWorkspace removes the 1-based workspace idx: its windows go to the
+// 1-based workspace target (deleteTarget's choice for 0), the ones after it
+// move down a workspace, and its name and metadata are dropped so the
+// names that follow stay aligned. It returns how many windows it moved off
+// the deleted workspace.
+func deleteWorkspace(idx, target int) (int, error) {
+	if err := requireWindowControl("delete"); err != nil {
+		return 0, err
+	}
+	sc, err := staticCount("delete")
+	if err != nil {
+		return 0, err
+	}
+	if sc < 2 {
+		return 0, errors.New("can't delete the only workspace")
+	}
+	if idx < 1 || idx > sc {
+		return 0, fmt.Errorf("no workspace %d (there are %d)", idx, sc)
+	}
+	if target == 0 {
+		target = deleteTarget(idx)
+	}
+	if target < 1 || target > sc || target == idx {
+		return 0, fmt.Errorf("can't move windows to workspace %d", target)
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return 0, err
+	}
+	active, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	del := idx - 1
+	to := func(d int) int {
+		if d == del {
+			d = target - 1
+		}
+		if d > del {
+			d--
+		}
+		return d
+	}
+	moved := 0
+	for _, w := range wins {
+		if w.Desktop == del {
+			moved++
+		}
+	}
+	if err := relocateWindows(wins, to); err != nil {
+		return 0, err
+	}
+	_ = remapHistory(func(d int) int {
+		if d == del {
+			return -1
+		}
+		return to(d)
+	})
+	if a := to(active); a != active {
+		if err := switchWorkspace(a + 1); err != nil {
+			return moved, err
+		}
+	}
+	invalidateMenuCache()
+	// The windows have moved, so the names follow before the count
+	// changes; a failed SetCount then only leaves an empty workspace at
+	// the end, and the count goes back to match it.
+	if err := updateConfig(func() error {
+		if del < len(cfg.Workspaces) {
+			cfg.Workspaces = append(cfg.Workspaces[:del], cfg.Workspaces[del+1:]...)
+		}
+		if cfg.Count > 0 {
+			cfg.Count = sc - 1
+		}
+		return nil
+	}); err != nil {
+		return moved, err
+	}
+	if err := backend.SetCount(sc - 1); err != nil {
+		_ = updateConfig(func() error {
+			if cfg.Count > 0 {
+				cfg.Count = sc
+			}
+			return nil
+		})
+		return moved, err
+	}
+	return moved, restoreDynamic()
+}
+
+// insertWorkspace adds a workspace at the 1-based position idx, named name
+// unless that is empty. The windows from idx on move up a workspace, and
+// their names with them.
+func insertWorkspace(idx int, name string) error {
+	if err := requireWindowControl("insert"); err != nil {
+		return err
+	}
+	sc, err := staticCount("insert")
+	if err != nil {
+		return err
+	}
+	if idx < 1 || idx > sc+1 {
+		return fmt.Errorf("can't insert at %d (there are %d workspaces)", idx, sc)
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return err
+	}
+	active, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return err
+	}
+
+	invalidateMenuCache()
+	if err := backend.SetCount(sc + 1); err != nil {
+		return err
+	}
+	at := idx - 1
+	to := func(d int) int {
+		if d >= at {
+			d++
+		}
+		return d
+	}
+	if err := relocateWindows(wins, to); err != nil {
+		return err
+	}
+	_ = remapHistory(to)
+	if a := to(active); a != active {
+		if err := switchWorkspace(a + 1); err != nil {
+			return err
+		}
+	}
+	if err := updateConfig(func() error {
+		if at < len(cfg.Workspaces) {
+			cfg.Workspaces = append(cfg.Workspaces, WorkspaceMeta{})
+			copy(cfg.Workspaces[at+1:], cfg.Workspaces[at:])
+			cfg.Workspaces[at] = WorkspaceMeta{}
+		} else {
+			ensureWorkspaces(at + 1)
+		}
+		cfg.Workspaces[at].Name = name
+		if cfg.Workspaces[at].Name == "" {
+			cfg.Workspaces[at].Name = defaultName(at)
+		}
+		if cfg.Count > 0 {
+			cfg.Count = sc + 1
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	runCreateHooks(idx, idx, sc+1)
+	return nil
+}
+
+// swapWorkspaces exchanges the 1-based workspaces a and b: their windows
+// trade places along with their names and metadata. The active workspace's
+// windows are followed to their new position.
+func swapWorkspaces(a, b int) error {
+	if err := requireWindowControl("reorder"); err != nil {
+		return err
+	}
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return err
+	}
+	for _, i := range []int{a, b} {
+		if i < 1 || i > sc {
+			return fmt.Errorf("no workspace %d (there are %d)", i, sc)
+		}
+	}
+	if a == b {
+		return nil
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return err
+	}
+	active, err := getActiveWorkspaceIndex()
+	if err != nil {
+		return err
+	}
+
+	to := func(d int) int {
+		switch d {
+		case a - 1:
+			return b - 1
+		case b - 1:
+			return a - 1
+		}
+		return d
+	}
+	invalidateMenuCache()
+	if err := relocateWindows(wins, to); err != nil {
+		return err
+	}
+	_ = remapHistory(to)
+	if t := to(active); t != active {
+		if err := switchWorkspace(t + 1); err != nil {
+			return err
+		}
+	}
+	return updateConfig(func() error {
+		ensureWorkspaces(max(a, b))
+		cfg.Workspaces[a-1], cfg.Workspaces[b-1] = cfg.Workspaces[b-1], cfg.Workspaces[a-1]
+		return nil
+	})
+}
+
+// setWorkspaceCount makes there be exactly n workspaces. Growing adds empty
+// ones at the end. Shrinking refuses to drop workspaces holding windows
+// unless force is set, which moves those windows to the new last
+// workspace; names beyond n are trimmed either way.
+func setWorkspaceCount(n int, force bool) error {
+	if n < 1 {
+		return errors.New("workspaces must be >= 1")
+	}
+	sc, err := staticCount("resize")
+	if err != nil {
+		return err
+	}
+	if n < sc {
+		wins, err := listWindows()
+		if err != nil {
+			return err
+		}
+		occupied := map[int]bool{}
+		for _, w := range wins {
+			if w.Desktop >= n {
+				occupied[w.Desktop] = true
+			}
+		}
+		if len(occupied) > 0 && !force {
+			var names []string
+			for d := n; d < sc; d++ {
+				if occupied[d] {
+					names = append(names, nameForIndex(d))
+				}
+			}
+			return fmt.Errorf("workspaces to remove still hold windows (%s); use --force to move them to %s",
+				strings.Join(names, ", "), nameForIndex(n-1))
+		}
+		to := func(d int) int { return min(d, n-1) }
+		if err := relocateWindows(wins, to); err != nil {
+			return err
+		}
+		active, err := getActiveWorkspaceIndex()
+		if err != nil {
+			return err
+		}
+		if a := to(active); a != active {
+			if err := switchWorkspace(a + 1); err != nil {
+				return err
+			}
+		}
+	}
+	if n != sc {
+		invalidateMenuCache()
+		if err := backend.SetCount(n); err != nil {
+			return err
+		}
+	}
+	if err := updateConfig(func() error {
+		if len(cfg.Workspaces) > n {
+			cfg.Workspaces = cfg.Workspaces[:n]
+		}
+		ensureWorkspaces(n)
+		if cfg.Count > 0 {
+			cfg.Count = n
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if n < sc {
+		return restoreDynamic()
+	}
+	return nil
+}
+
+// newWorkspace adds one workspace named name (the default name when empty)
+// at the end, or right after the active one with afterCurrent, and returns
+// its 1-based index. With dynamic workspaces on, GNOME always keeps an
+// empty workspace at the end, so that one is named instead.
+func newWorkspace(name string, afterCurrent bool) (int, error) {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return 0, err
+	}
+	if dyn, _ := getDynamic(); dyn {
+		if afterCurrent {
+			return 0, errors.New("dynamic workspaces are on; new workspaces can only go at the end")
+		}
+		if name == "" {
+			name = defaultName(sc - 1)
+		}
+		return sc, renameLocal(sc, name)
+	}
+	idx := sc + 1
+	if afterCurrent {
+		active, err := getActiveWorkspaceIndex()
+		if err != nil {
+			return 0, err
+		}
+		idx = active + 2
+	}
+	return idx, insertWorkspace(idx, name)
+}
+
+// dynamicRestoreFile holds the workspace count at which `create --static`
+// turned dynamic workspaces off. Once removing workspaces brings the count
+// back to it, restoreDynamic turns them on again.
+var dynamicRestoreFile = filepath.Join(filepath.Dir(historyFile), "dynamic-restore")
+
+// rememberDynamic records count unless an earlier count is recorded
+// already, which then stays the one to get back to.
+func rememberDynamic(count int) error {
+	if _, err := os.Stat(dynamicRestoreFile); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dynamicRestoreFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dynamicRestoreFile, []byte(strconv.Itoa(count)+"\n"), 0644)
+}
+
+func forgetDynamic() {
+	_ = os.Remove(dynamicRestoreFile)
+}
+
+// restoreDynamic turns dynamic workspaces back on when create --static
+// turned them off and the count is down to where it was then.
+func restoreDynamic() error {
+	b, err := os.ReadFile(dynamicRestoreFile)
+	if err != nil {
+		return nil
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		forgetDynamic()
+		return nil
+	}
+	sc, err := getSystemWorkspaceCount()
+	if err != nil || sc > count {
+		return err
+	}
+	return setDynamic(true)
+}
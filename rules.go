@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Window rules
+// -----------------------------------------------------------------------------
+
+// WindowRule sends windows whose class and title match to a workspace.
+// Class and Title are case-insensitive regular expressions; Class is
+// matched against wmctrl's "instance.Class", so "firefox" matches either
+// part. An empty pattern matches anything, but a rule needs at least one.
+// Workspace is a name, matched as by switch, or a displayed index.
+type WindowRule struct {
+	Class     string `yaml:"class,omitempty"`
+	Title     string `yaml:"title,omitempty"`
+	Workspace string `yaml:"workspace"`
+}
+
+// rulesInterval is how often the daemon looks for new windows when
+// rules_daemon is set.
+const rulesInterval = time.Second
+
+// compiledRule is a WindowRule with its patterns compiled.
+type compiledRule struct {
+	WindowRule
+	class, title *regexp.Regexp
+}
+
+// compileRules compiles cfg.Rules, reporting the first bad rule by its
+// 1-based position.
+func compileRules() ([]compiledRule, error) {
+	var out []compiledRule
+	for i, r := range cfg.Rules {
+		cr, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		out = append(out, cr)
+	}
+	return out, nil
+}
+
+func compileRule(r WindowRule) (compiledRule, error) {
+	cr := compiledRule{WindowRule: r}
+	if r.Class == "" && r.Title == "" {
+		return cr, errors.New("needs a class or a title pattern")
+	}
+	if r.Workspace == "" {
+		return cr, errors.New("has no workspace")
+	}
+	var err error
+	if r.Class != "" {
+		if cr.class, err = regexp.Compile("(?i)" + r.Class); err != nil {
+			return cr, fmt.Errorf("class: %v", err)
+		}
+	}
+	if r.Title != "" {
+		if cr.title, err = regexp.Compile("(?i)" + r.Title); err != nil {
+			return cr, fmt.Errorf("title: %v", err)
+		}
+	}
+	return cr, nil
+}
+
+func (r compiledRule) matches(w windowInfo) bool {
+	return (r.class == nil || r.class.MatchString(w.Class)) &&
+		(r.title == nil || r.title.MatchString(w.Title))
+}
+
+// ruleTarget resolves a rule's workspace to a 1-based index.
+func ruleTarget(ws string) (int, error) {
+	if i, err := strconv.Atoi(ws); err == nil {
+		return fromDisplayIndex(i, indexBase()), nil
+	}
+	return workspaceByName(ws, false)
+}
+
+// ruleMove is a window a rule sends elsewhere. To is 1-based.
+type ruleMove struct {
+	Window windowInfo
+	To     int
+}
+
+// planRules returns the moves the first matching rule of each window in
+// wins asks for. Sticky windows and those already in place are skipped.
+func planRules(rules []compiledRule, wins []windowInfo) ([]ruleMove, error) {
+	var moves []ruleMove
+	for _, w := range wins {
+		if w.Desktop < 0 {
+			continue
+		}
+		for _, r := range rules {
+			if !r.matches(w) {
+				continue
+			}
+			to, err := ruleTarget(r.Workspace)
+			if err != nil {
+				return nil, err
+			}
+			if to != w.Desktop+1 {
+				moves = append(moves, ruleMove{w, to})
+			}
+			break
+		}
+	}
+	return moves, nil
+}
+
+// applyRules moves every window a rule matches to its workspace and returns
+// the moves; with dryRun nothing is moved.
+func applyRules(dryRun bool) ([]ruleMove, error) {
+	rules, err := compileRules()
+	if err != nil {
+		return nil, err
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return nil, err
+	}
+	moves, err := planRules(rules, wins)
+	if err != nil || dryRun {
+		return moves, err
+	}
+	for _, m := range moves {
+		if err := moveWindow(m.Window.ID, m.To); err != nil {
+			return moves, fmt.Errorf("moving %q: %v", m.Window.Title, err)
+		}
+	}
+	return moves, nil
+}
+
+// rulesLoop applies the rules to windows that appear while rules_daemon is
+// set, until stop is closed. Windows open when it starts, or when the
+// setting is turned on, are left where they are; `gnav apply-rules`
+// handles those. So are windows `gnav launch` and templates place.
+func rulesLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(rulesInterval)
+	defer ticker.Stop()
+	var seen map[string]bool
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		cfgMu.Lock()
+		reloadConfig()
+		enabled := cfg.RulesDaemon
+		rules, err := compileRules()
+		cfgMu.Unlock()
+		if !enabled || err != nil {
+			seen = nil
+			continue
+		}
+		wins, err := listWindows()
+		if err != nil {
+			continue
+		}
+		var fresh []windowInfo
+		current := make(map[string]bool, len(wins))
+		for _, w := range wins {
+			current[w.ID] = true
+			if seen != nil && !seen[w.ID] {
+				fresh = append(fresh, w)
+			}
+		}
+		seen = current
+		cfgMu.Lock()
+		moves, err := planRules(rules, fresh)
+		cfgMu.Unlock()
+		if err != nil || len(moves) == 0 {
+			continue
+		}
+		placed := map[string]bool{}
+		for _, id := range placedWindows() {
+			placed[id] = true
+		}
+		for _, m := range moves {
+			if !placed[m.Window.ID] {
+				_ = moveWindow(m.Window.ID, m.To)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// -----------------------------------------------------------------------------
+// GNOME Shell search provider
+// -----------------------------------------------------------------------------
+
+// The daemon serves org.gnome.Shell.SearchProvider2 next to its own
+// interface, so workspace names typed in the Activities overview show up as
+// results. GNOME finds it through gnav.search-provider.ini (see README).
+// Result identifiers are 1-based workspace indices.
+const (
+	searchPath  = dbus.ObjectPath("/org/gnav/SearchProvider")
+	searchIface = "org.gnome.Shell.SearchProvider2"
+)
+
+const searchIntrospection = `
+<node>
+	<interface name="` + searchIface + `">
+		<method name="GetInitialResultSet">
+			<arg direction="in" type="as" name="terms"/>
+			<arg direction="out" type="as" name="results"/>
+		</method>
+		<method name="GetSubsearchResultSet">
+			<arg direction="in" type="as" name="previous_results"/>
+			<arg direction="in" type="as" name="terms"/>
+			<arg direction="out" type="as" name="results"/>
+		</method>
+		<method name="GetResultMetas">
+			<arg direction="in" type="as" name="identifiers"/>
+			<arg direction="out" type="aa{sv}" name="metas"/>
+		</method>
+		<method name="ActivateResult">
+			<arg direction="in" type="s" name="identifier"/>
+			<arg direction="in" type="as" name="terms"/>
+			<arg direction="in" type="u" name="timestamp"/>
+		</method>
+		<method name="LaunchSearch">
+			<arg direction="in" type="as" name="terms"/>
+			<arg direction="in" type="u" name="timestamp"/>
+		</method>
+	</interface>` + introspect.IntrospectDataString + `</node>`
+
+type searchProvider struct{ d *daemon }
+
+// exportSearchProvider serves searchIface on conn.
+func exportSearchProvider(conn *dbus.Conn, d *daemon) error {
+	if err := conn.Export(searchProvider{d}, searchPath, searchIface); err != nil {
+		return err
+	}
+	return conn.Export(introspect.Introspectable(searchIntrospection), searchPath,
+		"org.freedesktop.DBus.Introspectable")
+}
+
+func (p searchProvider) entries() ([]listEntry, *dbus.Error) {
+	reply, err := p.d.handle(daemonRequest{Cmd: "list"})
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	return reply.([]listEntry), nil
+}
+
+// search returns the identifiers of the workspaces matching terms, best
+// matches first.
+func (p searchProvider) search(terms []string) ([]string, *dbus.Error) {
+	entries, derr := p.entries()
+	if derr != nil {
+		return nil, derr
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	results := []string{}
+	for _, i := range rankNames(names, strings.Join(terms, " ")) {
+		results = append(results, strconv.Itoa(entries[i].Index))
+	}
+	return results, nil
+}
+
+func (p searchProvider) GetInitialResultSet(terms []string) ([]string, *dbus.Error) {
+	return p.search(terms)
+}
+
+// GetSubsearchResultSet searches afresh: there are few enough workspaces
+// that narrowing previous results would gain nothing.
+func (p searchProvider) GetSubsearchResultSet(_ []string, terms []string) ([]string, *dbus.Error) {
+	return p.search(terms)
+}
+
+func (p searchProvider) GetResultMetas(ids []string) ([]map[string]dbus.Variant, *dbus.Error) {
+	entries, derr := p.entries()
+	if derr != nil {
+		return nil, derr
+	}
+	metas := []map[string]dbus.Variant{}
+	for _, id := range ids {
+		idx, err := strconv.Atoi(id)
+		if err != nil || idx < 1 || idx > len(entries) {
+			continue
+		}
+		e := entries[idx-1]
+		desc := "Workspace " + e.Label()
+		if e.Active {
+			desc += " (active)"
+		}
+		metas = append(metas, map[string]dbus.Variant{
+			"id":          dbus.MakeVariant(id),
+			"name":        dbus.MakeVariant(e.Name),
+			"description": dbus.MakeVariant(desc),
+			"gicon":       dbus.MakeVariant("focus-windows-symbolic"),
+		})
+	}
+	return metas, nil
+}
+
+func (p searchProvider) ActivateResult(id string, _ []string, _ uint32) *dbus.Error {
+	if _, err := p.d.handle(daemonRequest{Cmd: "switch", Args: []string{id}}); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// LaunchSearch switches to the best match, as there is no gnav window to
+// show the full result list in.
+func (p searchProvider) LaunchSearch(terms []string, ts uint32) *dbus.Error {
+	results, derr := p.search(terms)
+	if derr != nil || len(results) == 0 {
+		return derr
+	}
+	return p.ActivateResult(results[0], terms, ts)
+}
@@ -0,0 +1,96 @@
+// Companion extension for gnav. GNOME Shell no longer lets other programs
+// run code in it through org.gnome.Shell.Eval, so this exports the few
+// calls gnav needs on the session bus, at /org/gnome/Shell/Extensions/Gnav
+// on org.gnome.Shell. Indices are 0-based; window ids are Meta.Window ids.
+
+import Gio from 'gi://Gio';
+import {Extension} from 'resource:///org/gnome/shell/extensions/extension.js';
+
+const IFACE = `
+<node>
+  <interface name="org.gnome.Shell.Extensions.Gnav">
+    <method name="Count"><arg type="i" direction="out"/></method>
+    <method name="ActiveIndex"><arg type="i" direction="out"/></method>
+    <method name="Switch"><arg type="i" name="index" direction="in"/></method>
+    <method name="Windows"><arg type="s" name="json" direction="out"/></method>
+    <method name="FocusWindow"><arg type="s" name="id" direction="in"/></method>
+    <method name="MoveWindow">
+      <arg type="s" name="id" direction="in"/>
+      <arg type="i" name="index" direction="in"/>
+    </method>
+    <method name="MoveActiveWindow"><arg type="i" name="index" direction="in"/></method>
+  </interface>
+</node>`;
+
+function windows() {
+    return global.get_window_actors()
+        .map(a => a.meta_window)
+        .filter(w => !w.is_skip_taskbar());
+}
+
+function findWindow(id) {
+    const w = windows().find(w => String(w.get_id()) === id);
+    if (!w)
+        throw new Error(`no window ${id}`);
+    return w;
+}
+
+function workspace(index) {
+    const ws = global.workspace_manager.get_workspace_by_index(index);
+    if (!ws)
+        throw new Error(`no workspace ${index}`);
+    return ws;
+}
+
+class GnavService {
+    Count() {
+        return global.workspace_manager.n_workspaces;
+    }
+
+    ActiveIndex() {
+        return global.workspace_manager.get_active_workspace_index();
+    }
+
+    Switch(index) {
+        workspace(index).activate(global.get_current_time());
+    }
+
+    Windows() {
+        return JSON.stringify(windows().map(w => ({
+            id: String(w.get_id()),
+            desktop: w.is_on_all_workspaces() ? -1 : w.get_workspace().index(),
+            class: `${w.get_wm_class_instance() ?? ''}.${w.get_wm_class() ?? ''}`,
+            title: w.get_title() ?? '',
+        })));
+    }
+
+    FocusWindow(id) {
+        findWindow(id).activate(global.get_current_time());
+    }
+
+    MoveWindow(id, index) {
+        workspace(index);
+        findWindow(id).change_workspace_by_index(index, false);
+    }
+
+    MoveActiveWindow(index) {
+        const w = global.display.focus_window;
+        if (!w)
+            throw new Error('no focused window');
+        workspace(index);
+        w.change_workspace_by_index(index, false);
+    }
+}
+
+export default class GnavExtension extends Extension {
+    enable() {
+        this._dbus = Gio.DBusExportedObject.wrapJSObject(IFACE, new GnavService());
+        this._dbus.export(Gio.DBus.session, '/org/gnome/Shell/Extensions/Gnav');
+    }
+
+    disable() {
+        this._dbus.flush();
+        this._dbus.unexport();
+        this._dbus = null;
+    }
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// GNOME Shell extension
+// -----------------------------------------------------------------------------
+
+const shellExtUUID = "gnav@ck-zhang.github.io"
+
+//go:embed shell-extension/gnav@ck-zhang.github.io
+var shellExtFiles embed.FS
+
+// shellExtDir is where GNOME Shell looks for the user's extensions.
+func shellExtDir(getenv func(string) string) string {
+	data := getenv("XDG_DATA_HOME")
+	if data == "" {
+		data = filepath.Join(getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(data, "gnome-shell", "extensions", shellExtUUID)
+}
+
+// installShellExtension copies the companion extension into the user's
+// extensions and tries to enable it, reporting whether that worked.
+// GNOME Shell on Wayland only picks up a new extension after logging in
+// again, so enabling a first install usually has to wait until then.
+func installShellExtension() (dir string, enabled bool, err error) {
+	dir = shellExtDir(os.Getenv)
+	root := path.Join("shell-extension", shellExtUUID)
+	err = fs.WalkDir(shellExtFiles, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := shellExtFiles.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(p, root+"/")))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(dst, b, 0644)
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return dir, exec.Command("gnome-extensions", "enable", shellExtUUID).Run() == nil, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Switch log and statistics
+// -----------------------------------------------------------------------------
+
+// switchLogFile records when each workspace became active, oldest first,
+// one "time<TAB>index<TAB>name" line per switch. The name is the one the
+// workspace had then, so time is counted per project even when the same
+// index is renamed.
+var switchLogFile = filepath.Join(filepath.Dir(historyFile), "switches.log")
+
+const (
+	// maxStint caps the time credited to a single stay on a workspace; a
+	// longer one most likely spans a locked screen or suspend.
+	maxStint = 4 * time.Hour
+
+	// Once the log grows past maxSwitchLog bytes, entries older than
+	// switchLogRetention are dropped.
+	maxSwitchLog       = 1 << 20
+	switchLogRetention = 90 * 24 * time.Hour
+)
+
+type switchEntry struct {
+	At    time.Time
+	Index int
+	Name  string
+}
+
+// logSwitch appends a switch to the 1-based workspace idx to the log.
+func logSwitch(idx int) {
+	if err := os.MkdirAll(filepath.Dir(switchLogFile), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(switchLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	name := strings.NewReplacer("\t", " ", "\n", " ").Replace(nameForIndex(idx - 1))
+	fmt.Fprintf(f, "%s\t%d\t%s\n", time.Now().Format(time.RFC3339), idx, name)
+	st, err := f.Stat()
+	f.Close()
+	if err == nil && st.Size() > maxSwitchLog {
+		pruneSwitchLog(time.Now().Add(-switchLogRetention))
+	}
+}
+
+func readSwitchLog() ([]switchEntry, error) {
+	f, err := os.Open(switchLogFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []switchEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			continue
+		}
+		idx, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, switchEntry{at, idx, parts[2]})
+	}
+	return entries, scanner.Err()
+}
+
+func pruneSwitchLog(before time.Time) {
+	entries, err := readSwitchLog()
+	if err != nil {
+		return
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		if e.At.After(before) {
+			fmt.Fprintf(&b, "%s\t%d\t%s\n", e.At.Format(time.RFC3339), e.Index, e.Name)
+		}
+	}
+	tmp := switchLogFile + ".tmp"
+	if os.WriteFile(tmp, []byte(b.String()), 0644) == nil {
+		_ = os.Rename(tmp, switchLogFile)
+	}
+}
+
+// workspaceTime is the time spent on one workspace name.
+type workspaceTime struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+// dayStats is the time spent per workspace on one day, longest first.
+type dayStats struct {
+	Date       string          `json:"date"`
+	Workspaces []workspaceTime `json:"workspaces"`
+}
+
+// switchStats totals the time spent per workspace on each of the days
+// days up to and including now's, oldest first. Each stay lasts until the
+// next switch, now, or maxStint, whichever comes first, and is split at
+// midnight.
+func switchStats(entries []switchEntry, now time.Time, days int) []dayStats {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	starts := make([]time.Time, days+1)
+	for i := range starts {
+		starts[i] = today.AddDate(0, 0, i-days+1)
+	}
+	totals := make([]map[string]time.Duration, days)
+	for i := range totals {
+		totals[i] = map[string]time.Duration{}
+	}
+	for i, e := range entries {
+		end := now
+		if i+1 < len(entries) {
+			end = entries[i+1].At
+		}
+		if limit := e.At.Add(maxStint); end.After(limit) {
+			end = limit
+		}
+		for d := 0; d < days; d++ {
+			from, to := e.At, end
+			if from.Before(starts[d]) {
+				from = starts[d]
+			}
+			if to.After(starts[d+1]) {
+				to = starts[d+1]
+			}
+			if to.After(from) {
+				totals[d][e.Name] += to.Sub(from)
+			}
+		}
+	}
+	out := make([]dayStats, days)
+	for d := range out {
+		out[d] = dayStats{Date: starts[d].Format("2006-01-02"), Workspaces: sortedTimes(totals[d])}
+	}
+	return out
+}
+
+// sumStats adds up the days' times per workspace.
+func sumStats(days []dayStats) []workspaceTime {
+	totals := map[string]time.Duration{}
+	for _, d := range days {
+		for _, w := range d.Workspaces {
+			totals[w.Name] += time.Duration(w.Seconds * float64(time.Second))
+		}
+	}
+	return sortedTimes(totals)
+}
+
+func sortedTimes(totals map[string]time.Duration) []workspaceTime {
+	out := []workspaceTime{}
+	for name, d := range totals {
+		if d = d.Round(time.Second); d > 0 {
+			out = append(out, workspaceTime{name, d.Seconds()})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Seconds != out[j].Seconds {
+			return out[i].Seconds > out[j].Seconds
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// formatStint renders seconds as e.g. "2h05m" or "12m".
+func formatStint(seconds float64) string {
+	m := int(seconds+30) / 60
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
+
+// printWorkspaceTimes prints one indented line per workspace.
+func printWorkspaceTimes(times []workspaceTime) {
+	if len(times) == 0 {
+		fmt.Println("  (nothing recorded)")
+		return
+	}
+	width := 0
+	for _, t := range times {
+		if l := len([]rune(t.Name)); l > width {
+			width = l
+		}
+	}
+	for _, t := range times {
+		fmt.Printf("  %-*s  %s\n", width, t.Name, formatStint(t.Seconds))
+	}
+}
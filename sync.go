@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// GNOME workspace-names sync
+// -----------------------------------------------------------------------------
+
+// GNOME keeps its own list of workspace names, shown in the overview and by
+// extensions. gnav can mirror its names there (push), adopt GNOME's (pull),
+// or merge the two, as selected by the name_sync config key.
+const (
+	nameSyncOff   = "off"
+	nameSyncPush  = "push"
+	nameSyncPull  = "pull"
+	nameSyncMerge = "merge"
+)
+
+// nameSyncMode returns the configured name_sync mode, "off" by default.
+func nameSyncMode() string {
+	switch m := strings.ToLower(cfg.NameSync); m {
+	case nameSyncPush, nameSyncPull, nameSyncMerge:
+		return m
+	}
+	return nameSyncOff
+}
+
+func gnomeWorkspaceNames() ([]string, error) {
+	out, err := exec.Command("gsettings", "get",
+		"org.gnome.desktop.wm.preferences", "workspace-names").Output()
+	if err != nil {
+		return nil, fmt.Errorf("gsettings: %v", err)
+	}
+	return parseStringArray(string(out))
+}
+
+func setGnomeWorkspaceNames(names []string) error {
+	out, err := exec.Command("gsettings", "set",
+		"org.gnome.desktop.wm.preferences", "workspace-names", formatStringArray(names)).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("gsettings: %v: %s", err, msg)
+		}
+		return fmt.Errorf("gsettings: %v", err)
+	}
+	return nil
+}
+
+// parseStringArray parses a GVariant string array as printed by gsettings,
+// e.g. ['Work', "Bob's"] or @as [].
+func parseStringArray(s string) ([]string, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "@as"))
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("unexpected string array: %q", s)
+	}
+	s = s[1 : len(s)-1]
+	var names []string
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return names, nil
+		}
+		quote := s[0]
+		if quote != '\'' && quote != '"' {
+			return nil, fmt.Errorf("unexpected string array element: %q", s)
+		}
+		var b strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != quote; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			b.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("unterminated string in array: %q", s)
+		}
+		names = append(names, b.String())
+		s = s[i+1:]
+	}
+}
+
+// formatStringArray is the inverse of parseStringArray.
+func formatStringArray(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		n = strings.ReplaceAll(n, `\`, `\\`)
+		quoted[i] = "'" + strings.ReplaceAll(n, "'", `\'`) + "'"
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// gnavNames returns the configured names with defaults blanked, so GNOME
+// falls back to its own default labels for them.
+func gnavNames() []string {
+	names := make([]string, len(cfg.Workspaces))
+	for i, w := range cfg.Workspaces {
+		if w.Name != defaultName(i) {
+			names[i] = w.Name
+		}
+	}
+	for len(names) > 0 && names[len(names)-1] == "" {
+		names = names[:len(names)-1]
+	}
+	return names
+}
+
+// mergeNames combines gnav's and GNOME's names position by position. A name
+// that is blank on one side is taken from the other; when both are set and
+// differ, the side named by prefer ("gnav" or "gnome") wins.
+func mergeNames(local, remote []string, prefer string) []string {
+	n := len(local)
+	if len(remote) > n {
+		n = len(remote)
+	}
+	merged := make([]string, n)
+	for i := range merged {
+		var l, r string
+		if i < len(local) {
+			l = local[i]
+		}
+		if i < len(remote) {
+			r = remote[i]
+		}
+		switch {
+		case l == "":
+			merged[i] = r
+		case r == "" || prefer != "gnome":
+			merged[i] = l
+		default:
+			merged[i] = r
+		}
+	}
+	return merged
+}
+
+// pushNames writes gnav's names to GNOME.
+func pushNames() error {
+	return setGnomeWorkspaceNames(gnavNames())
+}
+
+// syncNames brings gnav and GNOME in line according to mode (push, pull or
+// merge) and returns one line per gnav name that changed.
+func syncNames(mode string) ([]string, error) {
+	if mode == nameSyncPush {
+		return nil, pushNames()
+	}
+	remote, err := gnomeWorkspaceNames()
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	err = updateConfig(func() error {
+		changes = nil
+		names := remote
+		if mode == nameSyncMerge {
+			names = mergeNames(gnavNames(), remote, cfg.NameSyncPrefer)
+		}
+		for i, n := range names {
+			if n == "" {
+				continue
+			}
+			ensureWorkspaces(i + 1)
+			if old := cfg.Workspaces[i].Name; old != n {
+				cfg.Workspaces[i].Name = n
+				changes = append(changes, fmt.Sprintf("%d: %q -> %q", i+1, old, n))
+			}
+		}
+		if len(changes) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err != nil {
+		return changes, err
+	}
+	if mode == nameSyncMerge && formatStringArray(gnavNames()) != formatStringArray(remote) {
+		return changes, pushNames()
+	}
+	return changes, nil
+}
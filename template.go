@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Templates
+// -----------------------------------------------------------------------------
+
+// A template is a saved work session: the workspaces' names and metadata
+// and the apps to launch on each. Templates live in configDir/templates,
+// one file per template in any config format.
+type workspaceTemplate struct {
+	Workspaces []templateWorkspace `yaml:"workspaces"`
+}
+
+type templateWorkspace struct {
+	WorkspaceMeta `yaml:",inline"`
+	Launch        []string `yaml:"launch,omitempty"`
+}
+
+func templateDir() string {
+	return filepath.Join(configDir(os.Getenv), "templates")
+}
+
+func templateFile(name string) string {
+	return findConfigFile(filepath.Join(templateDir(), name+".yaml"))
+}
+
+// captureTemplate describes the current workspaces. An app's launch command
+// is guessed from its window class, e.g. "firefox" for firefox.Firefox, so
+// a saved template is worth a look before it is applied.
+func captureTemplate() (*workspaceTemplate, error) {
+	sc, err := getSystemWorkspaceCount()
+	if err != nil {
+		return nil, err
+	}
+	wins, err := listWindows()
+	if err != nil {
+		return nil, err
+	}
+	t := &workspaceTemplate{Workspaces: make([]templateWorkspace, sc)}
+	for i := range t.Workspaces {
+		t.Workspaces[i].WorkspaceMeta = workspaceMeta(i)
+		t.Workspaces[i].Name = nameForIndex(i)
+	}
+	seen := map[string]bool{}
+	for _, w := range wins {
+		if w.Desktop < 0 || w.Desktop >= sc {
+			continue
+		}
+		app := strings.ToLower(strings.SplitN(w.Class, ".", 2)[0])
+		key := fmt.Sprintf("%d/%s", w.Desktop, app)
+		if app == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		t.Workspaces[w.Desktop].Launch = append(t.Workspaces[w.Desktop].Launch, app)
+	}
+	// GNOME's trailing empty workspace in dynamic mode isn't part of the
+	// session.
+	if dyn, _ := getDynamic(); dyn && sc > 1 && len(t.Workspaces[sc-1].Launch) == 0 {
+		t.Workspaces = t.Workspaces[:sc-1]
+	}
+	return t, nil
+}
+
+// saveTemplate writes the current workspaces as the template name, refusing
+// to replace an existing one unless force is set. It returns the file.
+func saveTemplate(name string, force bool) (string, error) {
+	if err := checkProfileName(name); err != nil {
+		return "", fmt.Errorf("invalid template name %q", name)
+	}
+	path := templateFile(name)
+	if _, err := os.Stat(path); err == nil && !force {
+		return "", fmt.Errorf("template %q exists; use --force to replace it", name)
+	}
+	t, err := captureTemplate()
+	if err != nil {
+		return "", err
+	}
+	b, err := encodeConfig(path, t)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, b, 0644)
+}
+
+func loadTemplate(name string) (*workspaceTemplate, error) {
+	if err := checkProfileName(name); err != nil {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+	path := templateFile(name)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no template %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t workspaceTemplate
+	if err := decodeConfig(path, b, &t); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(t.Workspaces) == 0 {
+		return nil, fmt.Errorf("%s: no workspaces", path)
+	}
+	return &t, nil
+}
+
+// applyTemplate names the first workspaces after t's, adding static
+// workspaces when there are too few, and then, with launch, starts each
+// workspace's apps one after another and moves their windows there. report
+// is told about every app as it opens or fails to; failures don't stop the
+// rest.
+func applyTemplate(t *workspaceTemplate, launch bool, report func(cmd string, idx int, err error)) error {
+	n := len(t.Workspaces)
+	if err := updateConfig(func() error {
+		ensureWorkspaces(n)
+		for i, w := range t.Workspaces {
+			cfg.Workspaces[i] = w.WorkspaceMeta
+			if strings.TrimSpace(w.Name) == "" {
+				cfg.Workspaces[i].Name = defaultName(i)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	dyn, err := getDynamic()
+	if err != nil {
+		return err
+	}
+	if sc, err := getSystemWorkspaceCount(); err != nil {
+		return err
+	} else if !dyn && sc < n && !onDemandWorkspaces() {
+		// On-demand backends create the workspace once launchOn moves a
+		// window there.
+		invalidateMenuCache()
+		if err := backend.SetCount(n); err != nil {
+			return fmt.Errorf("adding workspaces: %v", err)
+		}
+	}
+	if !launch {
+		return nil
+	}
+	failed, total := 0, 0
+	for i, w := range t.Workspaces {
+		for _, cmd := range w.Launch {
+			total++
+			idx := i + 1
+			if dyn {
+				// Dynamic workspaces only grow by filling the empty last
+				// one, so the workspaces are filled in order.
+				if sc, err := getSystemWorkspaceCount(); err == nil && idx > sc {
+					idx = sc
+				}
+			}
+			_, err := launchOn(cmd, idx, launchTimeout)
+			if err != nil {
+				failed++
+			}
+			report(cmd, idx, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d apps didn't start", failed, total)
+	}
+	return nil
+}
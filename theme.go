@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// -----------------------------------------------------------------------------
+// Theme
+// -----------------------------------------------------------------------------
+
+// Theme colors the TUI and the active row of markup menus. Colors are hex
+// values or names ("#1e1e2e", "red"); an empty one uses the terminal's
+// default. Preset picks a base from themePresets ("catppuccin" unless set),
+// and every color given next to it overrides the preset's.
+type Theme struct {
+	Preset       string `yaml:"preset,omitempty"`
+	Background   string `yaml:"background,omitempty"`
+	Contrast     string `yaml:"contrast,omitempty"`
+	MoreContrast string `yaml:"more_contrast,omitempty"`
+	Border       string `yaml:"border,omitempty"`
+	Text         string `yaml:"text,omitempty"`
+	Highlight    string `yaml:"highlight,omitempty"`
+	WofiActive   string `yaml:"wofi_active,omitempty"`
+}
+
+const defaultThemePreset = "catppuccin"
+
+var themePresets = map[string]Theme{
+	"catppuccin": {
+		Background:   "#1E1E2E",
+		Contrast:     "#313244",
+		MoreContrast: "#45475A",
+		Border:       "#F5E0DC",
+		Text:         "#D9E0EE",
+		Highlight:    "#D9E0EE",
+		WofiActive:   "#ff5555",
+	},
+	"gruvbox": {
+		Background:   "#282828",
+		Contrast:     "#3C3836",
+		MoreContrast: "#504945",
+		Border:       "#D5C4A1",
+		Text:         "#EBDBB2",
+		Highlight:    "#FABD2F",
+		WofiActive:   "#FB4934",
+	},
+	"nord": {
+		Background:   "#2E3440",
+		Contrast:     "#3B4252",
+		MoreContrast: "#434C5E",
+		Border:       "#D8DEE9",
+		Text:         "#ECEFF4",
+		Highlight:    "#88C0D0",
+		WofiActive:   "#BF616A",
+	},
+	// none leaves every color to the terminal and the launcher's own style.
+	"none": {},
+}
+
+// currentTheme resolves the configured theme against its preset. An unknown
+// preset falls back to the default one.
+func currentTheme() Theme {
+	t, ok := themePresets[strings.ToLower(cfg.Theme.Preset)]
+	if !ok {
+		t = themePresets[defaultThemePreset]
+	}
+	override := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	o := cfg.Theme
+	override(&t.Background, o.Background)
+	override(&t.Contrast, o.Contrast)
+	override(&t.MoreContrast, o.MoreContrast)
+	override(&t.Border, o.Border)
+	override(&t.Text, o.Text)
+	override(&t.Highlight, o.Highlight)
+	override(&t.WofiActive, o.WofiActive)
+	return t
+}
+
+func setTUIViewTheme() {
+	t := currentTheme()
+	tview.Styles.PrimitiveBackgroundColor = tcell.GetColor(t.Background)
+	tview.Styles.ContrastBackgroundColor = tcell.GetColor(t.Contrast)
+	tview.Styles.MoreContrastBackgroundColor = tcell.GetColor(t.MoreContrast)
+	tview.Styles.BorderColor = tcell.GetColor(t.Border)
+	tview.Styles.TitleColor = tcell.GetColor(t.Border)
+	tview.Styles.GraphicsColor = tcell.GetColor(t.Border)
+	tview.Styles.PrimaryTextColor = tcell.GetColor(t.Text)
+	tview.Styles.SecondaryTextColor = tcell.GetColor(t.Text)
+	tview.Styles.TertiaryTextColor = tcell.GetColor(t.Text)
+	tview.Styles.InverseTextColor = tcell.GetColor(t.Background)
+	tview.Styles.ContrastSecondaryTextColor = tcell.GetColor(t.Border)
+}
+
+// styleList applies the theme's highlight to the selected row of l.
+func styleList(l *tview.List) {
+	t := currentTheme()
+	if t.Highlight == "" {
+		// Without colors, reverse video still marks the selection.
+		l.SetSelectedStyle(tcell.StyleDefault.Reverse(true))
+		return
+	}
+	l.SetSelectedBackgroundColor(tcell.GetColor(t.Highlight))
+	l.SetSelectedTextColor(tcell.GetColor(t.Background))
+}